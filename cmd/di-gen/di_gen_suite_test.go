@@ -0,0 +1,33 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+)
+
+// update rewrites the golden files of the testdata: go test ./cmd/di-gen/... -args -update.
+var update = flag.Bool("update", false, "update the golden files") //nolint:gochecknoglobals
+
+func TestDIGen(t *testing.T) { //nolint:paralleltest
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DI Gen Suite")
+}
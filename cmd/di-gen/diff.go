@@ -0,0 +1,143 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines surrounding the changes of a hunk.
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns the unified diff between the actual content of the file at path and its expected content.
+func unifiedDiff(path, actual, expected string) string {
+	ops := diffLines(splitLines(actual), splitLines(expected))
+
+	// aLines[k] & bLines[k] are the number of lines of actual & expected before ops[k].
+	aLines, bLines := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	changes := make([]int, 0)
+
+	for k, op := range ops {
+		aLines[k+1], bLines[k+1] = aLines[k], bLines[k]
+
+		if op.kind != '+' {
+			aLines[k+1]++
+		}
+
+		if op.kind != '-' {
+			bLines[k+1]++
+		}
+
+		if op.kind != ' ' {
+			changes = append(changes, k)
+		}
+	}
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "--- %s\n+++ %s (generated)\n", path, path)
+
+	for c := 0; c < len(changes); {
+		// a hunk includes the following changes separated by at most 2*diffContext unchanged lines.
+		last := c
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*diffContext+1 {
+			last++
+		}
+
+		start := max(changes[c]-diffContext, 0)
+		end := min(changes[last]+diffContext+1, len(ops))
+
+		fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(aLines[start], aLines[end]), hunkRange(bLines[start], bLines[end]))
+
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(buf, "%c%s\n", op.kind, op.line)
+		}
+
+		c = last + 1
+	}
+
+	return buf.String()
+}
+
+// hunkRange returns the range of the lines [from, to) of a hunk, e.g.: "3,7". The range of an empty hunk starts at the
+// line preceding it.
+func hunkRange(from, to int) string {
+	if from == to {
+		return fmt.Sprintf("%d,0", from)
+	}
+
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// diffLines returns the edit script turning a into b, using the longest common subsequence of their lines.
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] & b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', line: a[i]})
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{kind: '-', line: a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', line: b[j]})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{kind: '-', line: a[i]})
+	}
+
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{kind: '+', line: b[j]})
+	}
+
+	return ops
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+	"strconv"
+	"strings"
+)
+
+// lines returns the numbers 1 to n, one per line, replacing the lines of replaced.
+func lines(n int, replaced map[int]string) string {
+	b := &strings.Builder{}
+
+	for i := 1; i <= n; i++ {
+		if line, ok := replaced[i]; ok {
+			b.WriteString(line + "\n")
+		} else {
+			b.WriteString(strconv.Itoa(i) + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+var _ = Describe("unifiedDiff", func() {
+	DescribeTable("should return the unified diff of the actual and expected contents",
+		func(actual, expected, diff string) {
+			Expect(unifiedDiff("file.go", actual, expected)).To(Equal("--- file.go\n+++ file.go (generated)\n" + diff))
+		},
+		Entry("identical contents", "a\nb\n", "a\nb\n", ""),
+		Entry("a missing file", "", "a\nb\n", "@@ -0,0 +1,2 @@\n+a\n+b\n"),
+		Entry("a removed file", "a\nb\n", "", "@@ -1,2 +0,0 @@\n-a\n-b\n"),
+		Entry("a changed line", "a\nb\nc\n", "a\nB\nc\n", "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"),
+		Entry("an added line", "a\nc\n", "a\nb\nc\n", "@@ -1,2 +1,3 @@\n a\n+b\n c\n"),
+		Entry("a missing trailing newline", "a\nb", "a\nb\n", ""),
+		Entry("the changes of a hunk, with 3 lines of context",
+			lines(20, nil), lines(20, map[int]string{10: "ten", 12: "twelve"}),
+			"@@ -7,9 +7,9 @@\n 7\n 8\n 9\n-10\n+ten\n 11\n-12\n+twelve\n 13\n 14\n 15\n",
+		),
+		Entry("the changes of several hunks",
+			lines(30, nil), lines(30, map[int]string{2: "two", 25: "twenty-five"}),
+			"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n"+
+				"@@ -22,7 +22,7 @@\n 22\n 23\n 24\n-25\n+twenty-five\n 26\n 27\n 28\n",
+		),
+	)
+
+	DescribeTable("hunkRange",
+		func(from, to int, expected string) {
+			Expect(hunkRange(from, to)).To(Equal(expected))
+		},
+		Entry("an empty range", 3, 3, "3,0"),
+		Entry("a range", 2, 5, "3,3"),
+	)
+})
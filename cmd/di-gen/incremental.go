@@ -0,0 +1,448 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/alexandremahdhaoui/di/pkg/gen"
+	"golang.org/x/tools/go/packages"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sigs.k8s.io/controller-tools/pkg/version"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// aggregateGenerators describe all the packages at once, thus they cannot run against a subset of the packages.
+var aggregateGenerators = map[string]bool{ //nolint:gochecknoglobals
+	gen.ManifestMarkerName: true,
+	gen.DocsMarkerName:     true,
+}
+
+// cacheEntryMaxAge is the duration after which the unused entries of the cache are removed.
+const cacheEntryMaxAge = 30 * 24 * time.Hour
+
+// incremental restricts the generation to the packages whose sources changed.
+//
+// With a cache directory, the packages whose content hash is found in the cache are skipped, and the entries of the
+// cache unused for cacheEntryMaxAge are removed. The content hash of a
+// package covers the options of di-gen, the header files of the generators, the Go files of the package, including the
+// generated ones, its test files and the files excluded by its build constraints, and the content hashes of the
+// packages it imports, directly or not. The generated files import the packages of the typeImports and of the foreign
+// di.Containers, thus these packages are covered once generated.
+//
+// The content hash of an imported package covers its Go files if it belongs to the main module or to a module replaced
+// by a directory, the version of its module otherwise, or the Go version di-gen is built with for the standard library.
+//
+// With a list of changed files, the packages containing none of the files are skipped.
+type incremental struct {
+	// cacheDir is the directory of the cache, or an empty string if the cache is disabled.
+	cacheDir string
+	// changedDirs are the directories of the changed files, or nil if all the files may have changed.
+	changedDirs map[string]bool
+	// options are the options of di-gen but the paths.
+	options []string
+	// flags are the flags of di-gen changing the generated files, e.g. --merge.
+	flags []string
+	// headerFiles are the header files of the generators.
+	headerFiles []string
+	// paths are the paths of the packages to generate.
+	paths []string
+}
+
+// newIncremental returns an incremental generation of rawOpts.
+// changedFiles is the path of a file listing the changed files, one per line, or "-" to read the list from stdin.
+func newIncremental(rawOpts []string, cacheDir, changedFiles string, stdin io.Reader) (*incremental, error) {
+	inc := &incremental{
+		cacheDir:    cacheDir,
+		options:     make([]string, 0),
+		flags:       make([]string, 0),
+		headerFiles: make([]string, 0),
+		paths:       make([]string, 0),
+	}
+
+	for _, rawOpt := range rawOpts {
+		defn := optionsRegistry.Lookup("+"+strings.TrimPrefix(rawOpt, "+"), markers.DescribesPackage)
+		if defn == nil {
+			return nil, fmt.Errorf("unknown option %q", rawOpt)
+		}
+
+		val, err := defn.Parse("+" + strings.TrimPrefix(rawOpt, "+"))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse option %q: %w", rawOpt, err)
+		}
+
+		switch val := val.(type) {
+		case genall.InputPaths:
+			inc.paths = append(inc.paths, val...)
+
+			continue
+		case genall.Generator:
+			if aggregateGenerators[defn.Name] {
+				return nil, fmt.Errorf("the %s generator describes all the packages, it cannot run incrementally", defn.Name)
+			}
+
+			if headerFile := stringField(val, "HeaderFile"); headerFile != "" {
+				inc.headerFiles = append(inc.headerFiles, headerFile)
+			}
+		}
+
+		inc.options = append(inc.options, rawOpt)
+	}
+
+	sort.Strings(inc.options)
+	sort.Strings(inc.headerFiles)
+
+	if changedFiles == "" {
+		return inc, nil
+	}
+
+	changedDirs, err := readChangedDirs(changedFiles, stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	inc.changedDirs = changedDirs
+
+	return inc, nil
+}
+
+// readChangedDirs returns the absolute directories of the files listed in the file at path, or in stdin if path is "-".
+// The relative paths are resolved against the root returned by changedFilesRoot.
+func readChangedDirs(path string, stdin io.Reader) (map[string]bool, error) {
+	root, err := changedFilesRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	r := stdin
+
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		defer f.Close()
+
+		r = f
+	}
+
+	dirs := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(root, line)
+		}
+
+		dirs[filepath.Dir(filepath.Clean(line))] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read the changed files: %w", err)
+	}
+
+	return dirs, nil
+}
+
+// changedFilesRoot returns the root of the git repository of the working directory, since git diff --name-only lists
+// the files relative to it, or the root of the module of the working directory outside of a git repository.
+func changedFilesRoot() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err //nolint:wrapcheck
+	}
+
+	for dir := wd; ; dir = filepath.Dir(dir) {
+		// .git is a file in the worktrees and the submodules.
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		}
+
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	return findModuleDir(wd)
+}
+
+// filter returns rawOpts with the paths restricted to the changed packages, and these packages. The packages are only
+// listed, they are not type checked.
+func (inc *incremental) filter() ([]string, []*packages.Package, error) {
+	pkgs, hashes, err := inc.hashes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changed := make([]*packages.Package, 0)
+
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) == 0 {
+			continue
+		}
+
+		if inc.changedDirs != nil && !inc.changedDirs[filepath.Dir(pkg.GoFiles[0])] {
+			continue
+		}
+
+		if inc.cacheDir != "" {
+			entry := filepath.Join(inc.cacheDir, hashes[pkg.PkgPath])
+			if _, err := os.Stat(entry); err == nil {
+				// the entry is used, it is not pruned. At worst, it is pruned too early if its time cannot be changed.
+				now := time.Now()
+				_ = os.Chtimes(entry, now, now)
+
+				continue
+			}
+		}
+
+		changed = append(changed, pkg)
+	}
+
+	rawOpts := append(make([]string, 0, len(inc.options)+len(changed)), inc.options...)
+	for _, pkg := range changed {
+		rawOpts = append(rawOpts, "paths="+strconv.Quote(filepath.Dir(pkg.GoFiles[0])))
+	}
+
+	return rawOpts, changed, nil
+}
+
+// save stores the content hashes of the generated packages in the cache, and prunes the cache. It must be called once
+// the files are generated, so that the hashes cover the generated files.
+func (inc *incremental) save(generated []*packages.Package) error {
+	if inc.cacheDir == "" {
+		return nil
+	}
+
+	_, hashes, err := inc.hashes()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(inc.cacheDir, 0o755); err != nil { //nolint:gomnd
+		return err //nolint:wrapcheck
+	}
+
+	for _, pkg := range generated {
+		if err := os.WriteFile(filepath.Join(inc.cacheDir, hashes[pkg.PkgPath]), nil, 0o600); err != nil { //nolint:gomnd
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return pruneCache(inc.cacheDir, time.Now().Add(-cacheEntryMaxAge))
+}
+
+// pruneCache removes the entries of the cache dir last used before the time before. The other files are kept.
+func pruneCache(dir string, before time.Time) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isCacheEntry(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			// the entry was removed since the directory was read, or it is still used.
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+// isCacheEntry returns true if name is the name of an entry of the cache, i.e. a content hash.
+func isCacheEntry(name string) bool {
+	sum, err := hex.DecodeString(name)
+
+	return err == nil && len(sum) == sha256.Size
+}
+
+// hashes lists the packages of the paths, and returns them with the content hashes of these packages and of the
+// packages they import by package path.
+func (inc *incremental) hashes() ([]*packages.Package, map[string]string, error) {
+	pkgs, err := packages.Load(&packages.Config{ //nolint:exhaustruct
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedModule,
+	}, inc.paths...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot list the packages: %w", err)
+	}
+
+	options := sha256.New()
+	fmt.Fprintf(options, "%s\n%s\n%s\n", version.Version(), strings.Join(inc.options, "\n"), strings.Join(inc.flags, "\n"))
+
+	// the generators cannot run without their header files either.
+	if err := hashFiles(options, inc.headerFiles); err != nil {
+		return nil, nil, err
+	}
+
+	optionsSum := options.Sum(nil)
+
+	roots := make(map[string]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		roots[pkg.PkgPath] = true
+	}
+
+	hashes := make(map[string]string, len(pkgs))
+
+	var hash func(pkg *packages.Package) (string, error)
+
+	hash = func(pkg *packages.Package) (string, error) {
+		if sum, ok := hashes[pkg.PkgPath]; ok {
+			return sum, nil
+		}
+
+		h := sha256.New()
+		fmt.Fprintf(h, "%x\n%s\n", optionsSum, pkg.PkgPath)
+
+		if err := hashSources(h, pkg, roots[pkg.PkgPath]); err != nil {
+			return "", err
+		}
+
+		imports := make([]string, 0, len(pkg.Imports))
+		for path := range pkg.Imports {
+			imports = append(imports, path)
+		}
+
+		sort.Strings(imports)
+
+		for _, path := range imports {
+			sum, err := hash(pkg.Imports[path])
+			if err != nil {
+				return "", err
+			}
+
+			fmt.Fprintf(h, "%s %s\n", path, sum)
+		}
+
+		hashes[pkg.PkgPath] = hex.EncodeToString(h.Sum(nil))
+
+		return hashes[pkg.PkgPath], nil
+	}
+
+	for _, pkg := range pkgs {
+		if _, err := hash(pkg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return pkgs, hashes, nil
+}
+
+// hashSources writes the sources of pkg to h: its files if it is a root or if its module may be edited in place, the
+// version of its module or of the standard library otherwise.
+func hashSources(h io.Writer, pkg *packages.Package, root bool) error {
+	switch mod := pkg.Module; {
+	case root:
+	case mod == nil:
+		fmt.Fprintf(h, "go %s\n", runtime.Version())
+
+		return nil
+	case mod.Replace != nil && mod.Replace.Version != "":
+		fmt.Fprintf(h, "module %s@%s\n", mod.Replace.Path, mod.Replace.Version)
+
+		return nil
+	case !mod.Main && mod.Replace == nil && mod.Version != "":
+		fmt.Fprintf(h, "module %s@%s\n", mod.Path, mod.Version)
+
+		return nil
+	}
+
+	files := append(append(make([]string, 0), pkg.GoFiles...), pkg.OtherFiles...)
+
+	// the generated test files and the files generated with a buildTag are not built with the package, thus they are
+	// not listed in its GoFiles.
+	if root && len(pkg.GoFiles) > 0 {
+		testFiles, err := filepath.Glob(filepath.Join(filepath.Dir(pkg.GoFiles[0]), "*_test.go"))
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		files = append(append(files, pkg.IgnoredFiles...), testFiles...)
+	}
+
+	return hashFiles(h, files)
+}
+
+// hashFiles writes the names and the contents of the files to h, sorted by path. The duplicated files are written once.
+func hashFiles(h io.Writer, files []string) error {
+	files = append(make([]string, 0, len(files)), files...)
+	sort.Strings(files)
+	files = slices.Compact(files)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		fmt.Fprintf(h, "%s %d\n", filepath.Base(file), len(data))
+		h.Write(data) //nolint:errcheck
+	}
+
+	return nil
+}
+
+// stringField returns the string option name of the generator, if it has one, e.g. its HeaderFile.
+func stringField(generator genall.Generator, name string) string {
+	val := reflect.Indirect(reflect.ValueOf(generator))
+	if val.Kind() != reflect.Struct {
+		return ""
+	}
+
+	field := val.FieldByName(name)
+	if !field.IsValid() || field.Kind() != reflect.String {
+		return ""
+	}
+
+	return field.String()
+}
+
+// defaultCacheDir returns the di-gen directory of the user cache directory.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "di-gen")
+	}
+
+	return filepath.Join(dir, "di-gen")
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// modulePath is the path of the module of the packages written in the testdata.
+const modulePath = "github.com/alexandremahdhaoui/di/cmd/di-gen/"
+
+// writeFiles writes the files by path into a new directory of the testdata, removed at the end of the spec, and
+// returns its relative path. The packages of the directory stay in the module, thus they can import di.
+func writeFiles(files map[string]string) string {
+	dir, err := os.MkdirTemp("testdata", "tmp-")
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(os.RemoveAll, dir)
+
+	for path, content := range files {
+		Expect(os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, path), []byte(content), 0o600)).To(Succeed())
+	}
+
+	return dir
+}
+
+var _ = Describe("incremental", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = writeFiles(map[string]string{
+			"header.txt": "// Copyright YEAR.\n",
+			"b/b.go":     "package b\n\nimport \"fmt\"\n\nvar B = fmt.Sprint()\n",
+		})
+
+		// a imports b, out of the paths.
+		Expect(os.MkdirAll(filepath.Join(dir, "a"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "a", "a.go"),
+			[]byte("package a\n\nimport \""+modulePath+filepath.ToSlash(dir)+"/b\"\n\nvar _ = b.B\n"), 0o600)).
+			To(Succeed())
+	})
+
+	// hashOf returns the content hash of the package a of dir, generated with rawOpts.
+	hashOf := func(rawOpts ...string) string {
+		inc, err := newIncremental(append(rawOpts, "paths=./"+dir+"/a"), "", "", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		pkgs, hashes, err := inc.hashes()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkgs).To(HaveLen(1))
+
+		return hashes[pkgs[0].PkgPath]
+	}
+
+	Describe("hashes", func() {
+		It("should not change the hash of an unchanged package", func() {
+			Expect(hashOf("valuefunc")).To(Equal(hashOf("valuefunc")))
+		})
+
+		It("should change the hash of a package when the options change", func() {
+			Expect(hashOf("valuefunc")).NotTo(Equal(hashOf("valuefunc:year=2023")))
+		})
+
+		It("should change the hash of a package when its files change", func() {
+			previous := hashOf("valuefunc")
+			Expect(os.WriteFile(filepath.Join(dir, "a", "doc.go"), []byte("// Package a.\npackage a\n"), 0o600)).
+				To(Succeed())
+
+			Expect(hashOf("valuefunc")).NotTo(Equal(previous))
+		})
+
+		DescribeTable("should change the hash of a package when the files that are not built with it change",
+			func(name, content string) {
+				path := filepath.Join(dir, "a", name)
+				Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+				previous := hashOf("valuefunc")
+
+				By("editing the file")
+				Expect(os.WriteFile(path, []byte(content+"\nvar _ = 1\n"), 0o600)).To(Succeed())
+				edited := hashOf("valuefunc")
+				Expect(edited).NotTo(Equal(previous))
+
+				By("removing the file")
+				Expect(os.Remove(path)).To(Succeed())
+				Expect(hashOf("valuefunc")).NotTo(BeElementOf(previous, edited))
+			},
+			Entry("a generated test file", "zz_generated.di.valuefunc_test.go", "package a\n"),
+			Entry("an external test file", "a_test.go", "package a_test\n"),
+			Entry("a file excluded by a build tag", "zz_generated.di.valuefunc.go", "//go:build wasm\n\npackage a\n"),
+		)
+
+		It("should change the hash of a package when a package it imports out of the paths changes", func() {
+			previous := hashOf("valuefunc")
+			Expect(os.WriteFile(filepath.Join(dir, "b", "doc.go"), []byte("// Package b.\npackage b\n"), 0o600)).
+				To(Succeed())
+
+			Expect(hashOf("valuefunc")).NotTo(Equal(previous))
+		})
+
+		It("should change the hash of a package when the header file changes", func() {
+			header := "valuefunc:headerFile=" + filepath.Join(dir, "header.txt")
+			previous := hashOf(header)
+			Expect(os.WriteFile(filepath.Join(dir, "header.txt"), []byte("// Copyright YEAR, the authors.\n"), 0o600)).
+				To(Succeed())
+
+			Expect(hashOf(header)).NotTo(Equal(previous))
+		})
+
+		It("should fail if the header file is missing", func() {
+			inc, err := newIncremental([]string{
+				"valuefunc:headerFile=" + filepath.Join(dir, "missing.txt"), "paths=./" + dir + "/a",
+			}, "", "", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, err = inc.hashes()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("readChangedDirs", func() {
+		It("should resolve the changed files against the root of the repository", func() {
+			root, err := filepath.Abs(filepath.Join("..", ".."))
+			Expect(err).NotTo(HaveOccurred())
+
+			dirs, err := readChangedDirs("-", strings.NewReader("cmd/di-gen/main.go\n\n  README.md\n/abs/dir/file.go\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dirs).To(Equal(map[string]bool{
+				filepath.Join(root, "cmd", "di-gen"): true,
+				root:                                 true,
+				"/abs/dir":                           true,
+			}))
+		})
+
+		It("should read the changed files from a file", func() {
+			changed := filepath.Join(writeFiles(map[string]string{"changed.txt": "pkg/gen/gen.go\n"}), "changed.txt")
+
+			dirs, err := readChangedDirs(changed, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			root, err := filepath.Abs(filepath.Join("..", ".."))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dirs).To(Equal(map[string]bool{filepath.Join(root, "pkg", "gen"): true}))
+		})
+	})
+
+	Describe("newIncremental", func() {
+		It("should separate the sorted options from the paths", func() {
+			inc, err := newIncremental([]string{
+				"valuefunc:headerFile=hack/header.txt", "paths=./a", "container", "output:dir=out",
+			}, "cache", "", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(inc.cacheDir).To(Equal("cache"))
+			Expect(inc.options).To(Equal([]string{"container", "output:dir=out", "valuefunc:headerFile=hack/header.txt"}))
+			Expect(inc.headerFiles).To(Equal([]string{"hack/header.txt"}))
+			Expect(inc.paths).To(Equal([]string{"./a"}))
+			Expect(inc.changedDirs).To(BeNil())
+		})
+
+		DescribeTable("should fail with invalid options",
+			func(rawOpt string) {
+				_, err := newIncremental([]string{rawOpt, "paths=./a"}, "", "", nil)
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("an unknown option", "unknown"),
+			Entry("an invalid option", "valuefunc:year"),
+			Entry("an aggregate generator", "manifest"),
+		)
+	})
+
+	Describe("filter & save", func() {
+		var (
+			cacheDir string
+			absA     string
+		)
+
+		BeforeEach(func() {
+			cacheDir = filepath.Join(dir, "cache")
+
+			var err error
+			absA, err = filepath.Abs(filepath.Join(dir, "a"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		// filter returns the paths of the packages of a and b to generate.
+		filter := func(changedDirs map[string]bool) []string {
+			inc, err := newIncremental([]string{"valuefunc", "paths=./" + dir + "/..."}, cacheDir, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			inc.changedDirs = changedDirs
+
+			rawOpts, generated, err := inc.filter()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rawOpts).To(HaveLen(len(generated) + 1))
+			Expect(rawOpts[0]).To(Equal("valuefunc"))
+
+			paths := make([]string, 0, len(generated))
+			for _, pkg := range generated {
+				paths = append(paths, strings.TrimPrefix(pkg.PkgPath, modulePath+filepath.ToSlash(dir)+"/"))
+			}
+
+			Expect(inc.save(generated)).To(Succeed())
+
+			return paths
+		}
+
+		It("should skip the packages found in the cache", func() {
+			Expect(filter(nil)).To(ConsistOf("a", "b"))
+			Expect(filter(nil)).To(BeEmpty())
+		})
+
+		It("should generate the changed packages and the packages importing them", func() {
+			Expect(filter(nil)).To(ConsistOf("a", "b"))
+			Expect(os.WriteFile(filepath.Join(dir, "b", "doc.go"), []byte("// Package b.\npackage b\n"), 0o600)).
+				To(Succeed())
+
+			Expect(filter(nil)).To(ConsistOf("a", "b"))
+		})
+
+		It("should only generate the packages of the changed dirs", func() {
+			Expect(filter(map[string]bool{absA: true})).To(ConsistOf("a"))
+			Expect(filter(map[string]bool{absA: true})).To(BeEmpty())
+			Expect(filter(nil)).To(ConsistOf("b"))
+		})
+
+		It("should prune the cache entries unused for cacheEntryMaxAge", func() {
+			Expect(filter(nil)).To(ConsistOf("a", "b"))
+
+			entries, err := filepath.Glob(filepath.Join(cacheDir, "*"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(2))
+
+			// the other files of the cache are kept.
+			other := filepath.Join(cacheDir, "other")
+			Expect(os.WriteFile(other, nil, 0o600)).To(Succeed())
+
+			old := time.Now().Add(-cacheEntryMaxAge - time.Hour)
+			for _, path := range append(entries, other) {
+				Expect(os.Chtimes(path, old, old)).To(Succeed())
+			}
+
+			// the entry of a is used, thus it is not pruned.
+			Expect(filter(map[string]bool{absA: true})).To(BeEmpty())
+
+			remaining, err := filepath.Glob(filepath.Join(cacheDir, "*"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(remaining).To(HaveLen(2))
+			Expect(remaining).To(ContainElement(other))
+			Expect(filter(nil)).To(ConsistOf("b"))
+		})
+	})
+})
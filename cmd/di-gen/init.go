@@ -0,0 +1,238 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go/format"
+	"go/token"
+	"golang.org/x/mod/modfile"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// diModulePath is the path of the module of the di library, required by the scaffolded packages.
+const diModulePath = "github.com/alexandremahdhaoui/di"
+
+// defaultHeader is the header file written by init, if the module has none, formatted with the current year. See
+// gen.HeaderData.
+const defaultHeader = `/*
+Copyright %d The {{ .Module }} Authors.
+*/
+`
+
+// docTemplate is the doc.go of the wiring package scaffolded by init.
+var docTemplate = template.Must(template.New("doc.go").Parse(`// {{ .ContainerName }} holds the Values of {{ .Module }}.
+// +di:container:name={{ .Container }},exported=true
+// Port is an example Value, declare the Values of the module with the valuefunc marker.
+// +di:valuefunc:name=port,container={{ .ContainerName }},type=int,setters=true
+
+// Package {{ .Package }} declares the containers and Values of {{ .Module }}.
+package {{ .Package }}
+
+//go:generate di-gen container:headerFile={{ .HeaderFile }} valuefunc:headerFile={{ .HeaderFile }} paths=.
+`)) //nolint:gochecknoglobals
+
+// initOptions are the flags of the init subcommand.
+type initOptions struct {
+	// dir is the directory of the wiring package, relative to the root of the module.
+	dir string
+	// headerFile is the path of the header file, relative to the root of the module.
+	headerFile string
+	// container is the name of the container declared in doc.go.
+	container string
+	// force overwrites the doc.go of the wiring package if it exists.
+	force bool
+}
+
+// docData is the data of docTemplate.
+type docData struct {
+	Module        string
+	Package       string
+	Container     string
+	ContainerName string
+	HeaderFile    string
+}
+
+// newInitCommand returns the init subcommand, scaffolding the wiring package of the module of the current directory.
+func newInitCommand() *cobra.Command {
+	o := initOptions{dir: "wiring", headerFile: "hack/boilerplate.go.txt", container: "app", force: false}
+
+	cmd := &cobra.Command{ //nolint:exhaustruct,exhaustivestruct
+		Use:   "init",
+		Short: "Scaffold the wiring package of the current module.",
+		Long: `Scaffold the wiring package of the module of the current directory: a doc.go declaring an example container
+and ValueFunc with markers, and a go:generate line running di-gen on the package. A default header file is written
+if the module has none.`,
+		Example: `	# Create ./wiring/doc.go and ./hack/boilerplate.go.txt, then generate the package
+	di-gen init
+	go generate ./wiring
+
+	# Create ./internal/di/doc.go declaring the "services" container
+	di-gen init --dir internal/di --container services`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			wd, err := os.Getwd()
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			if err := o.run(wd, c.OutOrStdout()); err != nil {
+				return noUsageError{err}
+			}
+
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().StringVar(&o.dir, "dir", o.dir, "directory of the wiring package, relative to the root of the module")
+	cmd.Flags().StringVar(&o.headerFile, "header-file", o.headerFile, "path of the header file of the generated files, relative to the root of the module") //nolint:lll
+	cmd.Flags().StringVar(&o.container, "container", o.container, "name of the container declared in doc.go")
+	cmd.Flags().BoolVar(&o.force, "force", o.force, "overwrite the doc.go of the wiring package if it exists")
+
+	return cmd
+}
+
+// run scaffolds the wiring package of the module of the directory wd, and reports the created files to out.
+func (o initOptions) run(wd string, out io.Writer) error {
+	moduleDir, err := findModuleDir(wd)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filepath.Join(moduleDir, "go.mod"))
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	mod, err := modfile.ParseLax(filepath.Join(moduleDir, "go.mod"), data, nil)
+	if err != nil || mod.Module == nil {
+		return fmt.Errorf("cannot parse the go.mod of %s: %w", moduleDir, err)
+	}
+
+	pkgDir := filepath.Join(moduleDir, filepath.FromSlash(o.dir))
+	pkgName := filepath.Base(pkgDir)
+
+	if !token.IsIdentifier(pkgName) {
+		return fmt.Errorf("%s is not a valid package name, choose another --dir", pkgName)
+	}
+
+	if !token.IsIdentifier(o.container) {
+		return fmt.Errorf("%s is not a valid container name", o.container)
+	}
+
+	docFile := filepath.Join(pkgDir, "doc.go")
+	if _, err := os.Stat(docFile); err == nil && !o.force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", docFile)
+	}
+
+	headerFile := filepath.Join(moduleDir, filepath.FromSlash(o.headerFile))
+	if _, err := os.Stat(headerFile); errors.Is(err, os.ErrNotExist) {
+		if err := writeFile(headerFile, []byte(fmt.Sprintf(defaultHeader, time.Now().Year())), out); err != nil {
+			return err
+		}
+	}
+
+	relHeaderFile, err := filepath.Rel(pkgDir, headerFile)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := docTemplate.Execute(buffer, docData{
+		Module:        mod.Module.Mod.Path,
+		Package:       pkgName,
+		Container:     o.container,
+		ContainerName: strings.ToUpper(o.container[:1]) + o.container[1:],
+		HeaderFile:    filepath.ToSlash(relHeaderFile),
+	}); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	doc, err := format.Source(buffer.Bytes())
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := writeFile(docFile, doc, out); err != nil {
+		return err
+	}
+
+	if !requires(mod, diModulePath) {
+		fmt.Fprintf(out, "\nthe module does not require %[1]s yet, run:\n\tgo get %[1]s\n", diModulePath)
+	}
+
+	pkgPath := pkgDir
+	if rel, err := filepath.Rel(wd, pkgDir); err == nil && !strings.HasPrefix(rel, "..") {
+		pkgPath = "./" + filepath.ToSlash(rel)
+	}
+
+	fmt.Fprintf(out, "\ndeclare the containers and Values in %s, then run:\n\tgo generate %s\n", docFile, pkgPath)
+
+	return nil
+}
+
+// findModuleDir returns the directory of the closest go.mod of dir.
+func findModuleDir(dir string) (string, error) {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d, nil
+		}
+
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("cannot find the go.mod of %s, run go mod init first", dir)
+		}
+	}
+}
+
+// requires returns true if mod requires the module path.
+func requires(mod *modfile.File, path string) bool {
+	if mod.Module.Mod.Path == path {
+		return true
+	}
+
+	for _, r := range mod.Require {
+		if r.Mod.Path == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeFile writes data to the file at path, creating its directory, and reports it to out.
+func writeFile(path string, data []byte, out io.Writer) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //nolint:gomnd
+		return err //nolint:wrapcheck
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil { //nolint:gomnd,gosec
+		return err //nolint:wrapcheck
+	}
+
+	fmt.Fprintf(out, "created %s\n", path)
+
+	return nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var _ = Describe("init", func() {
+	var (
+		moduleDir string
+		o         initOptions
+		out       *bytes.Buffer
+	)
+
+	readFile := func(path string) string {
+		data, err := os.ReadFile(filepath.Join(moduleDir, path))
+		Expect(err).NotTo(HaveOccurred())
+
+		return string(data)
+	}
+
+	BeforeEach(func() {
+		moduleDir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(moduleDir, "go.mod"), []byte("module example.com/m\n\ngo 1.21\n"), 0o600)).
+			To(Succeed())
+
+		o = initOptions{dir: "internal/wiring", headerFile: "hack/boilerplate.go.txt", container: "app", force: false}
+		out = &bytes.Buffer{}
+	})
+
+	It("should scaffold the wiring package into the module", func() {
+		Expect(o.run(filepath.Join(moduleDir, "internal"), out)).To(Succeed())
+
+		Expect(readFile("internal/wiring/doc.go")).To(Equal(`// App holds the Values of example.com/m.
+// +di:container:name=app,exported=true
+// Port is an example Value, declare the Values of the module with the valuefunc marker.
+// +di:valuefunc:name=port,container=App,type=int,setters=true
+
+// Package wiring declares the containers and Values of example.com/m.
+package wiring
+
+//go:generate di-gen container:headerFile=../../hack/boilerplate.go.txt valuefunc:headerFile=../../hack/boilerplate.go.txt paths=.
+`))
+
+		By("writing a header file with the current year")
+		Expect(readFile("hack/boilerplate.go.txt")).
+			To(Equal("/*\nCopyright " + strconv.Itoa(time.Now().Year()) + " The {{ .Module }} Authors.\n*/\n"))
+
+		Expect(out.String()).To(ContainSubstring("created " + filepath.Join(moduleDir, "internal/wiring/doc.go")))
+		Expect(out.String()).To(ContainSubstring("go get " + diModulePath))
+		Expect(out.String()).To(ContainSubstring("go generate ./wiring"))
+	})
+
+	It("should keep the header file of the module", func() {
+		Expect(os.MkdirAll(filepath.Join(moduleDir, "hack"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(moduleDir, "hack/boilerplate.go.txt"), []byte("// Header.\n"), 0o600)).
+			To(Succeed())
+
+		Expect(o.run(moduleDir, out)).To(Succeed())
+		Expect(readFile("hack/boilerplate.go.txt")).To(Equal("// Header.\n"))
+		Expect(out.String()).NotTo(ContainSubstring("boilerplate.go.txt"))
+	})
+
+	It("should not overwrite doc.go without force", func() {
+		Expect(o.run(moduleDir, out)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(moduleDir, "internal/wiring/doc.go"), []byte("package wiring\n"), 0o600)).
+			To(Succeed())
+
+		Expect(o.run(moduleDir, out)).To(MatchError(ContainSubstring("already exists, use --force to overwrite it")))
+		Expect(readFile("internal/wiring/doc.go")).To(Equal("package wiring\n"))
+
+		o.force = true
+		Expect(o.run(moduleDir, out)).To(Succeed())
+		Expect(readFile("internal/wiring/doc.go")).To(ContainSubstring("+di:container:name=app"))
+	})
+
+	DescribeTable("should report the invalid options",
+		func(update func(o *initOptions), expected string) {
+			update(&o)
+			Expect(o.run(moduleDir, out)).To(MatchError(expected))
+		},
+		Entry("a directory that is not a package name", func(o *initOptions) { o.dir = "internal/di-wiring" },
+			"di-wiring is not a valid package name, choose another --dir"),
+		Entry("an invalid container name", func(o *initOptions) { o.container = "my-app" },
+			"my-app is not a valid container name"),
+	)
+
+	It("should report the directories out of a module", func() {
+		dir := GinkgoT().TempDir()
+		Expect(o.run(dir, out)).To(MatchError(ContainSubstring("run go mod init first")))
+	})
+})
@@ -22,14 +22,17 @@ import (
 	"fmt"
 	"github.com/alexandremahdhaoui/di/pkg/gen"
 	"github.com/spf13/cobra"
+	"golang.org/x/tools/go/packages"
 	"io"
 	"os"
+	"path/filepath"
 	"sigs.k8s.io/controller-tools/pkg/genall"
 	"sigs.k8s.io/controller-tools/pkg/genall/help"
 	prettyhelp "sigs.k8s.io/controller-tools/pkg/genall/help/pretty"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 	"sigs.k8s.io/controller-tools/pkg/version"
 	"strings"
+	"time"
 )
 
 // Options are specified to controller-gen by turning generators and output rules into markers, and then parsing them
@@ -40,8 +43,14 @@ var (
 	// allGenerators maintains the list of all known generators, giving them names for use on the command line.
 	// each turns into a command line option, and has options for output forms.
 	allGenerators = map[string]genall.Generator{ //nolint:gochecknoglobals
-		gen.ValueFuncMarkerName: gen.ValueFuncGenerator{},
-		gen.ContainerMarkerName: gen.ContainerGenerator{},
+		gen.ValueFuncMarkerName:  gen.ValueFuncGenerator{},
+		gen.ContainerMarkerName:  gen.ContainerGenerator{},
+		gen.InjectorMarkerName:   gen.InjectorGenerator{},
+		gen.ProvideMarkerName:    gen.ProvideGenerator{},
+		gen.FakeMarkerName:       gen.FakeGenerator{},
+		gen.ManifestMarkerName:   gen.ManifestGenerator{},
+		gen.DocsMarkerName:       gen.DocsGenerator{},
+		gen.EntrypointMarkerName: gen.EntrypointGenerator{},
 	}
 
 	// allOutputRules defines the list of all known output rules, giving them names for use on the command line.
@@ -116,6 +125,123 @@ func main() {
 	helpLevel := 0
 	whichLevel := 0
 	showVersion := false
+	verify := false
+	prune := false
+	cache := false
+	cacheDir := defaultCacheDir()
+	changedOnly := ""
+	merge := ""
+	watch := false
+	watchInterval := time.Second
+
+	// generate runs the generators of rawOpts. With changedDirs, the generation is restricted to the packages of the
+	// changedDirs, as with --changed-only.
+	generate := func(c *cobra.Command, rawOpts []string, changedDirs map[string]bool) error {
+		// restrict the generation to the changed packages, the cache is not used to verify the generated files
+		var (
+			inc       *incremental
+			generated []*packages.Package
+		)
+
+		if (cache && !verify) || changedOnly != "" || changedDirs != nil {
+			incCacheDir := ""
+			if cache && !verify {
+				incCacheDir = cacheDir
+			}
+
+			var err error
+			if inc, err = newIncremental(rawOpts, incCacheDir, changedOnly, c.InOrStdin()); err != nil {
+				return err
+			}
+
+			if changedDirs != nil {
+				inc.changedDirs = changedDirs
+			}
+
+			if merge != "" {
+				inc.flags = append(inc.flags, "--merge="+merge)
+			}
+
+			if rawOpts, generated, err = inc.filter(); err != nil {
+				return noUsageError{err}
+			}
+
+			if len(generated) == 0 {
+				return nil
+			}
+		}
+
+		// otherwise, set up the runtime for actually running the generators
+		rt, err := genall.FromOptions(optionsRegistry, rawOpts)
+		if err != nil {
+			return err
+		}
+
+		if len(rt.Generators) == 0 {
+			return fmt.Errorf("no generators specified")
+		}
+
+		var (
+			v   *verifier
+			rec *recorder
+		)
+
+		if verify {
+			v = newVerifier()
+			rt.OutputRules = v.outputRules(rt.OutputRules)
+		} else {
+			rec = newRecorder()
+			rt.OutputRules = rec.outputRules(rt.OutputRules)
+		}
+
+		// the merged files are written to the output rules, thus they are verified too.
+		var m *merger
+		if merge != "" {
+			if filepath.Base(merge) != merge || filepath.Ext(merge) != ".go" {
+				return fmt.Errorf("invalid --merge filename %q, expected the name of a Go file", merge)
+			}
+
+			m = newMerger(merge)
+			rt.OutputRules = m.outputRules(rt.OutputRules)
+		}
+
+		if hadErrs := rt.Run(); hadErrs {
+			// don't obscure the actual error with a bunch of usage
+			return noUsageError{fmt.Errorf("not all generators ran successfully")}
+		}
+
+		if m != nil {
+			if err := m.write(); err != nil {
+				return noUsageError{err}
+			}
+		}
+
+		names, err := newGeneratedNames(rawOpts, merge)
+		if err != nil {
+			return err
+		}
+
+		if verify {
+			superseded, err := staleFiles(rt.Roots, names, v.generated())
+			if err != nil {
+				return noUsageError{err}
+			}
+
+			if err := v.verify(c.OutOrStdout(), superseded); err != nil {
+				return noUsageError{err}
+			}
+		} else if err := rec.removeStaleFiles(rt.Roots, names, prune, c.OutOrStdout()); err != nil {
+			return noUsageError{err}
+		}
+
+		if inc != nil {
+			if err := inc.save(generated); err != nil {
+				return noUsageError{fmt.Errorf("cannot save the cache: %w", err)}
+			}
+		}
+
+		return nil
+	}
 
 	cmd := &cobra.Command{ //nolint:exhaustruct,exhaustivestruct
 		Use:   "di-gen",
@@ -136,6 +262,54 @@ func main() {
 
 	# Explain the markers for generating Value Functions, and their arguments
 	di-gen valuefunc -ww
+
+	# Verify that the generated files are up-to-date, printing a diff of the stale files
+	di-gen container valuefunc paths=./... --verify
+
+	# Generate the containers & ValueFuncs of each package into a single zz_generated.di.go file
+	di-gen container valuefunc paths=./... --merge=zz_generated.di.go
+
+	# Remove the files superseded by the merged files, e.g. zz_generated.di.valuefunc.go
+	di-gen container valuefunc paths=./... --merge=zz_generated.di.go --prune
+
+	# Generate one file per container, built only without the wasm build tag
+	di-gen valuefunc:splitByContainer=true,buildTag=!wasm paths=./...
+
+	# Generate the ValueFuncs and their tests
+	di-gen valuefunc:tests=true paths=./...
+
+	# Only regenerate the packages whose sources changed since the last run
+	di-gen valuefunc container paths=./... --cache
+
+	# Only regenerate the packages of the files changed in the working tree
+	git diff --name-only | di-gen valuefunc container paths=./... --changed-only=-
+
+	# Generate injector funcs wiring the +di:injector:provide constructors of a package
+	di-gen injector paths=./...
+
+	# Generate the fakes of the interface-typed ValueFuncs marked with fake=true
+	di-gen fake paths=./...
+
+	# Generate the ValueFuncs & Providers of the types, constructors and fields marked with +di:provide & +di:inject
+	di-gen provide paths=./...
+
+	# Write a json manifest of the containers & ValueFuncs of a project to ./config/di-manifest.json
+	di-gen manifest:format=json paths=./... output:manifest:dir=./config
+
+	# Document the containers & Values of a project in ./DI.md
+	di-gen docs paths=./... output:docs:dir=.
+
+	# Generate the BuildContainers & ValidateContainers funcs of the containers of each package
+	di-gen entrypoint paths=./...
+
+	# Regenerate the packages whose markers change, until interrupted
+	di-gen valuefunc container paths=./... --watch
+
+	# Scaffold the wiring package of the current module, see di-gen init --help
+	di-gen init
+
+	# Rewrite the hand-written ValueFuncs of a project into valuefunc markers, see di-gen migrate --help
+	di-gen migrate ./...
 `,
 		RunE: func(c *cobra.Command, rawOpts []string) error {
 			// print version if asked for it
@@ -155,29 +329,42 @@ func main() {
 				return printMarkerDocs(c, rawOpts, whichLevel)
 			}
 
-			// otherwise, set up the runtime for actually running the generators
-			rt, err := genall.FromOptions(optionsRegistry, rawOpts)
-			if err != nil {
-				return err
+			if verify && prune {
+				return fmt.Errorf("--prune cannot be used with --verify")
 			}
 
-			if len(rt.Generators) == 0 {
-				return fmt.Errorf("no generators specified")
-			}
+			if watch {
+				if verify || changedOnly != "" {
+					return fmt.Errorf("--watch cannot be used with --verify or --changed-only")
+				}
 
-			if hadErrs := rt.Run(); hadErrs {
-				// don't obscure the actual error with a bunch of usage
-				return noUsageError{fmt.Errorf("not all generators ran successfully")}
+				return newWatcher(rawOpts, watchInterval, c.OutOrStdout(), c.ErrOrStderr()).
+					watch(c.Context(), func(rawOpts []string, changedDirs map[string]bool) error {
+						return generate(c, rawOpts, changedDirs)
+					})
 			}
 
-			return nil
+			return generate(c, rawOpts, nil)
 		},
-		SilenceUsage: true, // silence the usage, then print it out ourselves if it wasn't suppressed
+		// the options are passed as arguments, thus they are not mistaken for unknown subcommands.
+		Args:              cobra.ArbitraryArgs,
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true}, //nolint:exhaustruct
+		SilenceUsage:      true,                                             // silence the usage, then print it out ourselves if it wasn't suppressed
 	}
 
+	cmd.AddCommand(newInitCommand(), newMigrateCommand())
+
 	cmd.Flags().CountVarP(&whichLevel, "which-markers", "w", "print out all markers available with the requested generators\n(up to -www for the most detailed output, or -wwww for json output)") //nolint:lll
 	cmd.Flags().CountVarP(&helpLevel, "detailed-help", "h", "print out more detailed help\n(up to -hhh for the most detailed output, or -hhhh for json output)")                                   //nolint:lll
 	cmd.Flags().BoolVar(&showVersion, "version", false, "show version")
+	cmd.Flags().BoolVar(&verify, "verify", false, "regenerate the files into memory and fail with a diff if the files on disk are stale")           //nolint:lll
+	cmd.Flags().BoolVar(&prune, "prune", false, "remove the files of the generators that are no longer generated, e.g. after switching to --merge") //nolint:lll
+	cmd.Flags().BoolVar(&cache, "cache", false, "skip the packages whose sources and generated files are unchanged since the last run")             //nolint:lll
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", cacheDir, "directory of the cache of --cache")
+	cmd.Flags().StringVar(&merge, "merge", "", "merge the Go files generated for each package into a single file of this name, e.g. zz_generated.di.go")      //nolint:lll
+	cmd.Flags().BoolVar(&watch, "watch", false, "poll the Go files of the packages, and regenerate the packages whose +di markers changed until interrupted") //nolint:lll
+	cmd.Flags().DurationVar(&watchInterval, "watch-interval", watchInterval, "interval between the polls of --watch")
+	cmd.Flags().StringVar(&changedOnly, "changed-only", "", "only generate the packages of the files listed in this file, one per line (\"-\" for stdin), relative to the root of the git repository") //nolint:lll
 	cmd.Flags().Bool("help", false, "print out usage and a summary of options")
 	oldUsage := cmd.UsageFunc()
 	cmd.SetUsageFunc(func(cmd *cobra.Command) error {
@@ -185,6 +372,11 @@ func main() {
 			return err
 		}
 
+		// the subcommands have no options.
+		if cmd.HasParent() {
+			return nil
+		}
+
 		if helpLevel == 0 {
 			helpLevel = summaryHelp
 		}
@@ -197,15 +389,20 @@ func main() {
 		return helpForLevels(cmd.OutOrStdout(), cmd.OutOrStderr(), helpLevel, optionsRegistry, help.SortByOption)
 	})
 
-	if err := cmd.Execute(); err != nil {
+	if executed, err := cmd.ExecuteC(); err != nil {
 		var _t1 noUsageError
-		if noUsage := errors.Is(err, _t1); !noUsage {
+		if noUsage := errors.As(err, &_t1); !noUsage {
 			// print the usage unless we suppressed it
-			if err := cmd.Usage(); err != nil {
+			if err := executed.Usage(); err != nil {
 				panic(err)
 			}
 		}
 
+		// the markers are only documented by the generators, not by the subcommands.
+		if executed != cmd {
+			os.Exit(1)
+		}
+
 		_, err = fmt.Fprintf(
 			cmd.OutOrStderr(),
 			"run `%[1]s %[2]s -w` to see all available markers, or `%[1]s %[2]s -h` for usage\n",
@@ -0,0 +1,300 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	pathpkg "path"
+	"path/filepath"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// merger keeps the Go files generated for the packages in memory, then writes them as a single file per package.
+type merger struct {
+	mu sync.Mutex
+	// filename is the name of the merged file of each package.
+	filename string
+	// packages maps the import paths of the packages to their generated Go files.
+	packages map[string]*mergedPackage
+}
+
+// mergedPackage is the set of Go files generated for a package.
+type mergedPackage struct {
+	pkg *loader.Package
+	// rule is the output rule of the first generated file, used to write the merged file.
+	rule genall.OutputRule
+	// files maps the item paths of the generated files to their content.
+	files map[string][]byte
+}
+
+// mergeRule is a genall.OutputRule keeping the Go files generated for the packages in memory. The other artifacts,
+// including the test files, are written by rule.
+type mergeRule struct {
+	merger *merger
+	rule   genall.OutputRule
+}
+
+type mergeFile struct {
+	bytes.Buffer
+	merger   *merger
+	rule     genall.OutputRule
+	pkg      *loader.Package
+	itemPath string
+}
+
+func newMerger(filename string) *merger {
+	return &merger{filename: filename, packages: make(map[string]*mergedPackage)}
+}
+
+// outputRules wraps every output rule of rules with a mergeRule.
+func (m *merger) outputRules(rules genall.OutputRules) genall.OutputRules {
+	merged := genall.OutputRules{
+		Default:     mergeRule{merger: m, rule: rules.Default},
+		ByGenerator: make(map[*genall.Generator]genall.OutputRule, len(rules.ByGenerator)),
+	}
+
+	for generator, rule := range rules.ByGenerator {
+		merged.ByGenerator[generator] = mergeRule{merger: m, rule: rule}
+	}
+
+	return merged
+}
+
+// write merges the Go files generated for each package, and writes them to the file filename of the package.
+func (m *merger) write() error {
+	paths := make([]string, 0, len(m.packages))
+	for path := range m.packages {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		merged := m.packages[path]
+
+		src, err := mergeGoFiles(merged.files)
+		if err != nil {
+			return fmt.Errorf("cannot merge the files generated for %s: %w", path, err)
+		}
+
+		out, err := merged.rule.Open(merged.pkg, m.filename)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		n, err := out.Write(src)
+		if err == nil && n < len(src) {
+			err = io.ErrShortWrite
+		}
+
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+func (r mergeRule) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	// test files cannot be merged with the other files of the package.
+	if pkg == nil || filepath.Ext(itemPath) != ".go" || strings.HasSuffix(itemPath, "_test.go") {
+		return r.rule.Open(pkg, itemPath) //nolint:wrapcheck
+	}
+
+	return &mergeFile{merger: r.merger, rule: r.rule, pkg: pkg, itemPath: itemPath}, nil
+}
+
+func (f *mergeFile) Close() error {
+	f.merger.mu.Lock()
+	defer f.merger.mu.Unlock()
+
+	merged, ok := f.merger.packages[f.pkg.PkgPath]
+	if !ok {
+		merged = &mergedPackage{pkg: f.pkg, rule: f.rule, files: make(map[string][]byte)}
+		f.merger.packages[f.pkg.PkgPath] = merged
+	}
+
+	merged.files[f.itemPath] = f.Bytes()
+
+	return nil
+}
+
+// mergeGoFiles merges the Go files of a package, sorted by name, into a single file. The merged file keeps the
+// comments preceding the package clause of the first file, and the union of the imports.
+func mergeGoFiles(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var (
+		preamble, pkgName string
+		goBuild           string
+		// generators are the generators named by the headers of the files.
+		generators = make([]string, 0)
+	)
+
+	// imports maps the import paths to their explicit names, importNames maps the names of the imports to their paths,
+	// and pathNames maps the import paths to their names.
+	imports, importNames, pathNames := make(map[string]string), make(map[string]string), make(map[string]string)
+	bodies := make([]string, 0, len(names))
+	fset := token.NewFileSet()
+
+	for i, name := range names {
+		src := files[name]
+
+		file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		tokenFile := fset.File(file.Pos())
+		filePreamble := string(src[:tokenFile.Offset(file.Package)])
+
+		if i == 0 {
+			preamble, pkgName, goBuild = filePreamble, file.Name.Name, goBuildLine(filePreamble)
+		} else if goBuildLine(filePreamble) != goBuild {
+			return nil, fmt.Errorf("the build constraints of %s and %s differ", names[0], name)
+		}
+
+		generators = append(generators, preambleGenerators(filePreamble)...)
+
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return nil, err //nolint:wrapcheck
+			}
+
+			importName := pathpkg.Base(path)
+			if spec.Name != nil {
+				importName = spec.Name.Name
+			}
+
+			if other, ok := importNames[importName]; ok && other != path {
+				return nil, fmt.Errorf("%s is imported as %s, as is %s", path, importName, other)
+			}
+
+			// the merged file imports each path once, thus with a single name.
+			if other, ok := pathNames[path]; ok && other != importName {
+				return nil, fmt.Errorf("%s is imported as %s and as %s", path, other, importName)
+			}
+
+			importNames[importName], pathNames[path] = path, importName
+
+			if spec.Name != nil {
+				imports[path] = spec.Name.Name
+			} else if _, ok := imports[path]; !ok {
+				imports[path] = ""
+			}
+		}
+
+		// the body of the file starts after its imports, or after its package clause.
+		bodyStart := file.Name.End()
+
+		for _, decl := range file.Decls {
+			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+				bodyStart = genDecl.End()
+			}
+		}
+
+		bodies = append(bodies, string(src[tokenFile.Offset(bodyStart):]))
+	}
+
+	buffer := new(bytes.Buffer)
+	fmt.Fprintf(buffer, "%spackage %s\n", withGenerators(preamble, generators), pkgName)
+
+	if len(imports) > 0 {
+		paths := make([]string, 0, len(imports))
+		for path := range imports {
+			paths = append(paths, path)
+		}
+
+		sort.Strings(paths)
+		buffer.WriteString("\nimport (\n")
+
+		for _, path := range paths {
+			fmt.Fprintf(buffer, "\t%s %s\n", imports[path], strconv.Quote(path))
+		}
+
+		buffer.WriteString(")\n")
+	}
+
+	for _, body := range bodies {
+		buffer.WriteString(body)
+	}
+
+	return format.Source(buffer.Bytes()) //nolint:wrapcheck
+}
+
+// preambleGenerators returns the generators named by the header of the comments preceding a package clause.
+func preambleGenerators(preamble string) []string {
+	for _, line := range strings.Split(preamble, "\n") {
+		if match := generatedHeader.FindStringSubmatch(line); match != nil {
+			return strings.Fields(match[1])
+		}
+	}
+
+	return nil
+}
+
+// withGenerators returns the comments preceding a package clause, whose header names the sorted generators.
+func withGenerators(preamble string, generators []string) string {
+	if len(generators) == 0 {
+		return preamble
+	}
+
+	sort.Strings(generators)
+	generators = slices.Compact(generators)
+
+	lines := strings.Split(preamble, "\n")
+	for i, line := range lines {
+		if generatedHeader.MatchString(line) {
+			lines[i] = "// Code generated by di-gen " + strings.Join(generators, " ") + ". DO NOT EDIT."
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// goBuildLine returns the //go:build line of the comments preceding a package clause.
+func goBuildLine(preamble string) string {
+	for _, line := range strings.Split(preamble, "\n") {
+		if strings.HasPrefix(line, "//go:build ") {
+			return line
+		}
+	}
+
+	return ""
+}
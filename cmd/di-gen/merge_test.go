@@ -0,0 +1,111 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+)
+
+const mergePreamble = "//go:build !ignore_autogenerated\n\n// Code generated by di-gen. DO NOT EDIT.\n\n"
+
+var _ = Describe("mergeGoFiles", func() {
+	It("should merge the files into a single file, keeping the preamble of the first file", func() {
+		merged, err := mergeGoFiles(map[string][]byte{
+			"zz_generated.di.valuefunc.go": []byte(mergePreamble + `package a
+
+import (
+	"fmt"
+	di "github.com/alexandremahdhaoui/di"
+)
+
+func Port(options ...di.Option) di.Value[int] { return nil }
+
+var _ = fmt.Sprint
+`),
+			"zz_generated.di.container.go": []byte("//go:build !ignore_autogenerated\n\n// Other preamble.\n\n" +
+				`package a
+
+import "github.com/alexandremahdhaoui/di"
+
+var Container = di.New("Container")
+`),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(string(merged)).To(Equal("//go:build !ignore_autogenerated\n\n// Other preamble.\n\n" + `package a
+
+import (
+	"fmt"
+	di "github.com/alexandremahdhaoui/di"
+)
+
+var Container = di.New("Container")
+
+func Port(options ...di.Option) di.Value[int] { return nil }
+
+var _ = fmt.Sprint
+`))
+	})
+
+	It("should name the generators of the files in the header of the merged file", func() {
+		merged, err := mergeGoFiles(map[string][]byte{
+			"a.go": []byte("// Code generated by di-gen valuefunc. DO NOT EDIT.\n\npackage a\n"),
+			"b.go": []byte("// Code generated by di-gen container. DO NOT EDIT.\n\npackage a\n"),
+			"c.go": []byte("// Code generated by di-gen valuefunc. DO NOT EDIT.\n\npackage a\n"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(string(merged)).To(Equal("// Code generated by di-gen container valuefunc. DO NOT EDIT.\n\npackage a\n"))
+	})
+
+	DescribeTable("should fail to merge the files that cannot be merged",
+		func(errMessage string, files ...string) {
+			sources := make(map[string][]byte, len(files))
+			for i, file := range files {
+				sources["file"+string(rune('a'+i))+".go"] = []byte(file)
+			}
+
+			_, err := mergeGoFiles(sources)
+			Expect(err).To(MatchError(errMessage))
+		},
+		Entry("different build constraints",
+			"the build constraints of filea.go and fileb.go differ",
+			mergePreamble+"package a\n",
+			"//go:build !wasm\n\npackage a\n",
+		),
+		Entry("a missing build constraint",
+			"the build constraints of filea.go and fileb.go differ",
+			mergePreamble+"package a\n",
+			"package a\n",
+		),
+		Entry("different imports with the same name",
+			"example.com/b/fmt is imported as fmt, as is fmt",
+			"package a\n\nimport \"fmt\"\n\nvar _ = fmt.Sprint\n",
+			"package a\n\nimport \"example.com/b/fmt\"\n\nvar _ = fmt.B\n",
+		),
+		Entry("an import with different names",
+			"fmt is imported as fmt and as format",
+			"package a\n\nimport \"fmt\"\n\nvar _ = fmt.Sprint\n",
+			"package a\n\nimport format \"fmt\"\n\nvar _ = format.Sprint\n",
+		),
+		Entry("an invalid file",
+			"filea.go:1:1: expected 'package', found 'EOF'",
+			"",
+		),
+	)
+})
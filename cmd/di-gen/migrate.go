@@ -0,0 +1,838 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/alexandremahdhaoui/di/pkg/astutil"
+	"github.com/alexandremahdhaoui/di/pkg/diutil"
+	"github.com/alexandremahdhaoui/di/pkg/gen"
+	"github.com/spf13/cobra"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	goastutil "golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+	"io"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrateOptions are the flags of the migrate subcommand.
+type migrateOptions struct {
+	// dryRun prints the diff of the migrated files instead of writing them.
+	dryRun bool
+	// headerFile and year are the options of the valuefunc generator.
+	headerFile string
+	year       string
+}
+
+// migration is a hand-written ValueFunc, and the valuefunc marker replacing it.
+type migration struct {
+	fn *ast.FuncDecl
+	// name, container, typ & typeImports are the arguments of the marker.
+	name       string
+	container  string
+	typ        ast.Expr
+	typeImport []string
+	// qualifiers maps the package qualifiers of typ to the packages they refer to.
+	qualifiers map[string]*types.Package
+	// locals are the unqualified identifiers of typ declared by the package.
+	locals []string
+}
+
+// newMigrateCommand returns the migrate subcommand, rewriting the hand-written ValueFuncs into valuefunc markers.
+func newMigrateCommand() *cobra.Command {
+	o := migrateOptions{dryRun: false, headerFile: "", year: ""}
+
+	cmd := &cobra.Command{ //nolint:exhaustruct,exhaustivestruct
+		Use:   "migrate [packages]",
+		Short: "Rewrite the hand-written ValueFuncs into valuefunc markers.",
+		Long: `Rewrite the hand-written ValueFuncs of the packages into valuefunc markers, then generate them with the
+valuefunc generator. The packages default to ./... A hand-written ValueFunc is shaped like:
+
+	func Name(options ...di.Option) di.Value[T] {
+		return di.MustWithOptions[T](Container, "Name", options...)
+	}
+
+The comment of the func is kept above its marker. The funcs that cannot be generated with the same name and key, e.g.
+unexported funcs, are reported and left unchanged.`,
+		Example: `	# Print the diff of the migration of the packages of the module
+	di-gen migrate ./... --dry-run
+
+	# Migrate a package, prepending a header to the generated files
+	di-gen migrate ./internal/wiring --header-file=hack/boilerplate.go.txt --year=2023`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(c *cobra.Command, patterns []string) error {
+			if len(patterns) == 0 {
+				patterns = []string{"./..."}
+			}
+
+			if err := o.run(patterns, c.OutOrStdout()); err != nil {
+				return noUsageError{err}
+			}
+
+			return nil
+		},
+		SilenceUsage: true,
+	}
+
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", o.dryRun, "print the diff of the migrated files instead of writing them")
+	cmd.Flags().StringVar(&o.headerFile, "header-file", o.headerFile, "header file of the generated files, see valuefunc:headerFile") //nolint:lll
+	cmd.Flags().StringVar(&o.year, "year", o.year, "year of the header of the generated files, see valuefunc:year")
+
+	return cmd
+}
+
+// run migrates the packages matching patterns, and reports the migrated and skipped funcs to out.
+func (o migrateOptions) run(patterns []string, out io.Writer) error {
+	pkgs, err := packages.Load(&packages.Config{ //nolint:exhaustruct
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes |
+			packages.NeedTypesInfo | packages.NeedImports,
+	}, patterns...)
+	if err != nil {
+		return fmt.Errorf("cannot load the packages: %w", err)
+	}
+
+	// sources maps the paths of the migrated files to their migrated sources.
+	sources := make(map[string][]byte)
+	migrated := make([]string, 0)
+
+	for _, pkg := range pkgs {
+		// the types of the funcs are needed, thus the packages that do not type check are not migrated.
+		if len(pkg.Errors) > 0 {
+			fmt.Fprintf(out, "skipping package %s: %s\n", pkg.PkgPath, pkg.Errors[0])
+
+			continue
+		}
+
+		files, err := migratePackage(pkg, out)
+		if err != nil {
+			return err
+		}
+
+		if len(files) == 0 {
+			continue
+		}
+
+		for path, src := range files {
+			sources[path] = src
+			dir := filepath.Dir(path)
+
+			if len(migrated) == 0 || migrated[len(migrated)-1] != dir {
+				migrated = append(migrated, dir)
+			}
+		}
+	}
+
+	if o.dryRun {
+		return printMigrationDiffs(sources, out)
+	}
+
+	if len(migrated) == 0 {
+		return nil
+	}
+
+	originals, err := writeSources(sources)
+	if err != nil {
+		return errors.Join(err, restoreSources(originals))
+	}
+
+	// the ValueFuncs are generated into memory, so that the migrated files can be restored if the generation fails,
+	// instead of leaving packages with markers and without funcs.
+	if err := o.generate(migrated); err != nil {
+		return errors.Join(err, restoreSources(originals))
+	}
+
+	return nil
+}
+
+// generate runs the valuefunc generator against the migrated dirs, and writes the generated files if it succeeds.
+func (o migrateOptions) generate(dirs []string) error {
+	generator := "valuefunc"
+	if o.headerFile != "" {
+		generator += ":headerFile=" + strconv.Quote(o.headerFile) + ",year=" + strconv.Quote(o.year)
+	}
+
+	rawOpts := []string{generator}
+	for _, dir := range dirs {
+		rawOpts = append(rawOpts, "paths="+strconv.Quote(dir))
+	}
+
+	rt, err := genall.FromOptions(optionsRegistry, rawOpts)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	v := newVerifier()
+	rt.OutputRules = v.outputRules(rt.OutputRules)
+
+	if hadErrs := rt.Run(); hadErrs {
+		return errors.New("the migrated ValueFuncs were not generated successfully, the migrated files are restored")
+	}
+
+	return v.write()
+}
+
+// printMigrationDiffs writes the unified diffs of the migrated sources to out, sorted by path.
+func printMigrationDiffs(sources map[string][]byte, out io.Writer) error {
+	for _, path := range sortedPaths(sources) {
+		actual, err := os.ReadFile(path)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		fmt.Fprint(out, unifiedDiff(path, string(actual), string(sources[path])))
+	}
+
+	return nil
+}
+
+// writeSources writes the migrated sources, and returns the original sources of the written files by path.
+func writeSources(sources map[string][]byte) (map[string][]byte, error) {
+	originals := make(map[string][]byte, len(sources))
+
+	for _, path := range sortedPaths(sources) {
+		original, err := os.ReadFile(path)
+		if err != nil {
+			return originals, err //nolint:wrapcheck
+		}
+
+		if err := os.WriteFile(path, sources[path], 0o644); err != nil { //nolint:gomnd,gosec
+			return originals, err //nolint:wrapcheck
+		}
+
+		originals[path] = original
+	}
+
+	return originals, nil
+}
+
+// restoreSources writes back the original sources of the migrated files.
+func restoreSources(originals map[string][]byte) error {
+	errs := make([]error, 0)
+
+	for _, path := range sortedPaths(originals) {
+		if err := os.WriteFile(path, originals[path], 0o644); err != nil { //nolint:gomnd,gosec
+			errs = append(errs, fmt.Errorf("cannot restore %s: %w", path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// sortedPaths returns the sorted keys of files.
+func sortedPaths(files map[string][]byte) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// migratePackage returns the sources of the files of pkg whose ValueFuncs are migrated, by path.
+func migratePackage(pkg *packages.Package, out io.Writer) (map[string][]byte, error) {
+	migrations := make(map[*ast.File][]*migration)
+
+	for _, file := range pkg.Syntax {
+		if ast.IsGenerated(file) {
+			continue
+		}
+
+		path := pkg.Fset.File(file.Pos()).Name()
+
+		diPkgIdent, ok := diImportIdent(file)
+		if !ok {
+			continue
+		}
+
+		decls := astutil.ValuefuncDeclFromNode(file, astutil.Meta{ //nolint:exhaustruct
+			Pkg:      pkg.Name,
+			Filepath: path,
+			Module:   pkg.PkgPath,
+		}, diPkgIdent)
+
+		fns := make([]*ast.FuncDecl, 0, len(decls))
+
+		for _, decl := range decls {
+			if fn := funcDecl(file, decl.Decl.Ident.String()); fn != nil {
+				fns = append(fns, fn)
+			}
+		}
+
+		sort.Slice(fns, func(i, j int) bool { return fns[i].Pos() < fns[j].Pos() })
+
+		for _, fn := range fns {
+			m, err := newMigration(pkg, fn)
+			if err != nil {
+				fmt.Fprintf(out, "%s: skipping %s: %s\n", pkg.Fset.Position(fn.Pos()), fn.Name.Name, err)
+
+				continue
+			}
+
+			migrations[file] = append(migrations[file], m)
+		}
+	}
+
+	if len(migrations) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	// the imports used by the types of the valuefunc markers must stay used by the code of the package.
+	markerQualifiers := markerQualifiers(pkg)
+	kept := keptImports(pkg, migrations)
+
+	skipMigrations(pkg, migrations, out, func(m *migration) error {
+		for qualifier, imported := range m.qualifiers {
+			if markerQualifiers[qualifier] && !kept[imported.Path()][qualifier] {
+				return fmt.Errorf("the import %s is used by the type of a valuefunc marker, add it to the typeImport "+
+					"of the marker first", imported.Path())
+			}
+		}
+
+		return nil
+	})
+
+	// the funcs whose types cannot be resolved without their imports are left unchanged, thus their imports are kept.
+	kept = keptImports(pkg, migrations)
+
+	skipMigrations(pkg, migrations, out, func(m *migration) error {
+		return m.resolveQualifiers(kept)
+	})
+
+	files := make(map[string][]byte, len(migrations))
+
+	for _, file := range pkg.Syntax {
+		if len(migrations[file]) == 0 {
+			continue
+		}
+
+		path := pkg.Fset.File(file.Pos()).Name()
+
+		src, err := rewriteFile(pkg, file, migrations[file])
+		if err != nil {
+			return nil, fmt.Errorf("cannot migrate %s: %w", path, err)
+		}
+
+		for _, m := range migrations[file] {
+			fmt.Fprintf(out, "%s: migrated %s\n", pkg.Fset.Position(m.fn.Pos()), m.fn.Name.Name)
+		}
+
+		files[path] = src
+	}
+
+	return files, nil
+}
+
+// skipMigrations removes the migrations for which check returns an error, and reports them to out.
+func skipMigrations(pkg *packages.Package, migrations map[*ast.File][]*migration, out io.Writer,
+	check func(m *migration) error,
+) {
+	for _, file := range pkg.Syntax {
+		kept := make([]*migration, 0, len(migrations[file]))
+
+		for _, m := range migrations[file] {
+			if err := check(m); err != nil {
+				fmt.Fprintf(out, "%s: skipping %s: %s\n", pkg.Fset.Position(m.fn.Pos()), m.fn.Name.Name, err)
+
+				continue
+			}
+
+			kept = append(kept, m)
+		}
+
+		migrations[file] = kept
+	}
+}
+
+// newMigration returns the migration of fn, or an error if fn is not a hand-written ValueFunc that can be generated.
+func newMigration(pkg *packages.Package, fn *ast.FuncDecl) (*migration, error) { //nolint:cyclop
+	if fn.Recv != nil || fn.Type.TypeParams != nil || fn.Body == nil {
+		return nil, errors.New("not a func of the package")
+	}
+
+	if !fn.Name.IsExported() {
+		return nil, errors.New("the generated ValueFuncs are exported")
+	}
+
+	params := fn.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) != 1 {
+		return nil, errors.New("the func must only have the options ...di.Option parameter")
+	}
+
+	ellipsis, ok := params[0].Type.(*ast.Ellipsis)
+	if !ok || !isDIObject(pkg.TypesInfo.TypeOf(ellipsis.Elt), "Option") {
+		return nil, errors.New("the func must only have the options ...di.Option parameter")
+	}
+
+	results := fn.Type.Results
+	if results == nil || len(results.List) != 1 || len(results.List[0].Names) > 1 {
+		return nil, errors.New("the func must only return a di.Value[T]")
+	}
+
+	resultType, ok := results.List[0].Type.(*ast.IndexExpr)
+	if !ok || !isDIObject(pkg.TypesInfo.TypeOf(resultType), diutil.ValueIdent) {
+		return nil, errors.New("the func must only return a di.Value[T]")
+	}
+
+	call, ok := returnedCall(fn)
+	if !ok || len(call.Args) != 3 || !call.Ellipsis.IsValid() { //nolint:gomnd
+		return nil, errors.New("the func must only return di.MustWithOptions[T](container, key, options...)")
+	}
+
+	callee, ok := call.Fun.(*ast.IndexExpr)
+	if !ok || types.ExprString(callee.Index) != types.ExprString(resultType.Index) ||
+		!isDIFunc(pkg.TypesInfo, callee.X, diutil.MustWithOptionsIdent) {
+		return nil, errors.New("the func must only return di.MustWithOptions[T](container, key, options...)")
+	}
+
+	if options, ok := call.Args[2].(*ast.Ident); !ok || options.Name != params[0].Names[0].Name {
+		return nil, errors.New("the options must be passed to di.MustWithOptions")
+	}
+
+	key, err := keyOf(call.Args[1])
+	if err != nil || key != fn.Name.Name {
+		return nil, errors.New("the key of the Value must be the name of the func")
+	}
+
+	container, err := containerOf(pkg, call.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &migration{
+		fn:         fn,
+		name:       strings.ToLower(key[:1]) + key[1:],
+		container:  container,
+		typ:        resultType.Index,
+		typeImport: make([]string, 0),
+		qualifiers: qualifiersOf(pkg.TypesInfo, resultType.Index),
+		locals:     localsOf(pkg.TypesInfo, pkg.Types, resultType.Index),
+	}, nil
+}
+
+// resolveQualifiers adds the packages of the qualifiers of the type that are no longer imported by the package to the
+// typeImport of m, and renames these qualifiers after the names of their packages. kept maps the import paths that are
+// still imported by the package to the names they are imported as.
+func (m *migration) resolveQualifiers(kept map[string]map[string]bool) error {
+	renames := make(map[string]string)
+	names := make(map[string]string)
+
+	for qualifier, imported := range m.qualifiers {
+		if kept[imported.Path()][qualifier] {
+			continue
+		}
+
+		// the unqualified identifiers resolve to the types of the TypeImports first.
+		for _, local := range m.locals {
+			if obj := imported.Scope().Lookup(local); obj != nil && obj.Exported() {
+				return fmt.Errorf("the type %s of the package would resolve to %s.%s", local, imported.Path(), local)
+			}
+		}
+
+		if path, ok := names[imported.Name()]; ok {
+			return fmt.Errorf("the packages %s and %s of the type are both named %s", path, imported.Path(),
+				imported.Name())
+		}
+
+		names[imported.Name()] = imported.Path()
+		m.typeImport = append(m.typeImport, imported.Path())
+		renames[qualifier] = imported.Name()
+	}
+
+	sort.Strings(m.typeImport)
+
+	if len(renames) == 0 {
+		return nil
+	}
+
+	m.typ = goastutil.Apply(copyExpr(m.typ), func(c *goastutil.Cursor) bool {
+		if sel, ok := c.Node().(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && renames[x.Name] != "" {
+				c.Replace(&ast.SelectorExpr{X: ast.NewIdent(renames[x.Name]), Sel: ast.NewIdent(sel.Sel.Name)})
+			}
+
+			return false
+		}
+
+		return true
+	}, nil).(ast.Expr)
+
+	return nil
+}
+
+// marker returns the valuefunc marker of m.
+func (m *migration) marker() string {
+	args := []string{"name=" + m.name}
+	if m.container != "" {
+		args = append(args, "container="+m.container)
+	}
+
+	args = append(args, "type="+markerString(types.ExprString(m.typ)))
+
+	if len(m.typeImport) > 0 {
+		args = append(args, "typeImport="+markerString(strings.Join(m.typeImport, ";")))
+	}
+
+	return "// +di:valuefunc:" + strings.Join(args, ",")
+}
+
+// markerString quotes the marker argument s if it cannot be written as is.
+func markerString(s string) string {
+	if strings.ContainsAny(s, ",; \"") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// keptImports returns the import paths of pkg that are still used once the migrations are applied, with the names
+// they are imported as. The markers resolve the qualifiers of their types against these imports.
+func keptImports(pkg *packages.Package, migrations map[*ast.File][]*migration) map[string]map[string]bool {
+	kept := make(map[string]map[string]bool)
+
+	for _, file := range pkg.Syntax {
+		removed := make(map[ast.Node]bool)
+		for _, m := range migrations[file] {
+			removed[m.fn] = true
+		}
+
+		ast.Inspect(file, func(node ast.Node) bool {
+			if removed[node] {
+				return false
+			}
+
+			if ident, ok := node.(*ast.Ident); ok {
+				if pkgName, ok := pkg.TypesInfo.Uses[ident].(*types.PkgName); ok {
+					if kept[pkgName.Imported().Path()] == nil {
+						kept[pkgName.Imported().Path()] = make(map[string]bool)
+					}
+
+					kept[pkgName.Imported().Path()][ident.Name] = true
+				}
+			}
+
+			return true
+		})
+	}
+
+	return kept
+}
+
+// rewriteFile replaces the funcs of the migrations of file by their markers, and removes the unused imports.
+func rewriteFile(pkg *packages.Package, file *ast.File, migrations []*migration) ([]byte, error) {
+	tokenFile := pkg.Fset.File(file.Pos())
+
+	src, err := os.ReadFile(tokenFile.Name())
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].fn.Pos() < migrations[j].fn.Pos() })
+
+	buffer := new(bytes.Buffer)
+	offset := 0
+
+	for _, m := range migrations {
+		start := m.fn.Pos()
+		if m.fn.Doc != nil {
+			start = m.fn.Doc.Pos()
+		}
+
+		buffer.Write(src[offset:tokenFile.Offset(start)])
+
+		if m.fn.Doc != nil {
+			for _, comment := range m.fn.Doc.List {
+				buffer.WriteString(comment.Text + "\n")
+			}
+		}
+
+		buffer.WriteString(m.marker())
+
+		offset = tokenFile.Offset(m.fn.End())
+	}
+
+	buffer.Write(src[offset:])
+
+	fset := token.NewFileSet()
+
+	rewritten, err := parser.ParseFile(fset, tokenFile.Name(), buffer.Bytes(), parser.ParseComments)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	removeUnusedImports(fset, rewritten, pkg.TypesInfo, file)
+
+	formatted := new(bytes.Buffer)
+	if err := format.Node(formatted, fset, rewritten); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return formatted.Bytes(), nil
+}
+
+// removeUnusedImports removes the imports of rewritten that are no longer used. The names of the imported packages
+// are taken from the type-checked original file.
+func removeUnusedImports(fset *token.FileSet, rewritten *ast.File, info *types.Info, original *ast.File) {
+	used := make(map[string]bool)
+
+	ast.Inspect(rewritten, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				used[x.Name] = true
+			}
+		}
+
+		return true
+	})
+
+	for _, spec := range original.Imports {
+		pkgName := importedPkgName(info, spec)
+		if pkgName == nil || pkgName.Name() == "_" || pkgName.Name() == "." || used[pkgName.Name()] {
+			continue
+		}
+
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+
+		goastutil.DeleteNamedImport(fset, rewritten, name, pkgName.Imported().Path())
+	}
+}
+
+// importedPkgName returns the package name declared by the import spec.
+func importedPkgName(info *types.Info, spec *ast.ImportSpec) *types.PkgName {
+	if pkgName, ok := info.Implicits[spec].(*types.PkgName); ok {
+		return pkgName
+	}
+
+	if spec.Name != nil {
+		if pkgName, ok := info.Defs[spec.Name].(*types.PkgName); ok {
+			return pkgName
+		}
+	}
+
+	return nil
+}
+
+// markerQualifiers returns the package qualifiers of the types of the valuefunc markers of pkg that resolve against
+// the imports of pkg, i.e. that are not named after a package of their TypeImport.
+func markerQualifiers(pkg *packages.Package) map[string]bool {
+	qualifiers := make(map[string]bool)
+
+	for _, file := range pkg.Syntax {
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+				if !strings.HasPrefix(text, "+"+gen.ValueFuncMarkerDefinition.Name+":") {
+					continue
+				}
+
+				value, err := gen.ValueFuncMarkerDefinition.Parse(text)
+				if err != nil {
+					continue
+				}
+
+				valueFunc := value.(gen.ValueFunc) //nolint:forcetypeassert
+
+				expr, err := parser.ParseExpr(valueFunc.Type)
+				if err != nil {
+					continue
+				}
+
+				typeImports := make(map[string]bool)
+				if valueFunc.TypeImport != nil {
+					for _, typeImport := range strings.Split(*valueFunc.TypeImport, ";") {
+						typeImports[pathpkg.Base(strings.TrimSpace(typeImport))] = true
+					}
+				}
+
+				ast.Inspect(expr, func(node ast.Node) bool {
+					if sel, ok := node.(*ast.SelectorExpr); ok {
+						if x, ok := sel.X.(*ast.Ident); ok && !typeImports[x.Name] {
+							qualifiers[x.Name] = true
+						}
+					}
+
+					return true
+				})
+			}
+		}
+	}
+
+	return qualifiers
+}
+
+// containerOf returns the container argument of the marker of the container expr, or an empty string for the
+// di.DefaultContainer.
+func containerOf(pkg *packages.Package, expr ast.Expr) (string, error) {
+	var ident *ast.Ident
+
+	switch e := expr.(type) {
+	case *ast.Ident:
+		ident = e
+	case *ast.SelectorExpr:
+		ident = e.Sel
+	default:
+		return "", errors.New("the container must be a package-level var")
+	}
+
+	obj, ok := pkg.TypesInfo.Uses[ident].(*types.Var)
+	if !ok || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+		return "", errors.New("the container must be a package-level var")
+	}
+
+	switch {
+	case obj.Pkg().Path() == diutil.PkgPath && obj.Name() == "DefaultContainer":
+		return "", nil
+	case obj.Pkg() == pkg.Types:
+		return obj.Name(), nil
+	default:
+		return obj.Pkg().Path() + "." + obj.Name(), nil
+	}
+}
+
+// keyOf returns the value of the string literal expr.
+func keyOf(expr ast.Expr) (string, error) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", errors.New("the key must be a string literal")
+	}
+
+	return strconv.Unquote(lit.Value) //nolint:wrapcheck
+}
+
+// returnedCall returns the call returned by the single statement of fn.
+func returnedCall(fn *ast.FuncDecl) (*ast.CallExpr, bool) {
+	if len(fn.Body.List) != 1 {
+		return nil, false
+	}
+
+	ret, ok := fn.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return nil, false
+	}
+
+	call, ok := ret.Results[0].(*ast.CallExpr)
+
+	return call, ok
+}
+
+// qualifiersOf returns the package qualifiers of the type expr, and the packages they refer to.
+func qualifiersOf(info *types.Info, expr ast.Expr) map[string]*types.Package {
+	qualifiers := make(map[string]*types.Package)
+
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok {
+			if pkgName, ok := info.Uses[ident].(*types.PkgName); ok {
+				qualifiers[ident.Name] = pkgName.Imported()
+			}
+		}
+
+		return true
+	})
+
+	return qualifiers
+}
+
+// localsOf returns the unqualified identifiers of the type expr declared by the package pkg.
+func localsOf(info *types.Info, pkg *types.Package, expr ast.Expr) []string {
+	locals := make([]string, 0)
+
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			return false
+		case *ast.Ident:
+			if obj, ok := info.Uses[node].(*types.TypeName); ok && obj.Pkg() == pkg {
+				locals = append(locals, node.Name)
+			}
+		}
+
+		return true
+	})
+
+	return locals
+}
+
+// isDIObject returns true if typ is the type name of the di package, or an instance of it.
+func isDIObject(typ types.Type, name string) bool {
+	named, ok := typ.(*types.Named)
+
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == diutil.PkgPath && named.Obj().Name() == name
+}
+
+// isDIFunc returns true if expr refers to the func name of the di package.
+func isDIFunc(info *types.Info, expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	if sel, isSel := expr.(*ast.SelectorExpr); isSel {
+		ident, ok = sel.Sel, true
+	}
+
+	if !ok {
+		return false
+	}
+
+	fn, ok := info.Uses[ident].(*types.Func)
+
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == diutil.PkgPath && fn.Name() == name
+}
+
+// funcDecl returns the declaration of the func name in file.
+func funcDecl(file *ast.File, name string) *ast.FuncDecl {
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+			return fn
+		}
+	}
+
+	return nil
+}
+
+// diImportIdent returns the identifier of the di package in file, if it is imported.
+func diImportIdent(file *ast.File) (astutil.Ident, bool) {
+	for _, spec := range file.Imports {
+		if pkgImport := astutil.NewPkgImport(spec); pkgImport.Pkg() == diutil.PkgPath {
+			return pkgImport.Ident(), true
+		}
+	}
+
+	return "", false
+}
+
+// copyExpr returns a deep copy of the type expression expr, without positions.
+func copyExpr(expr ast.Expr) ast.Expr {
+	copied, err := parser.ParseExpr(types.ExprString(expr))
+	if err != nil {
+		return expr
+	}
+
+	return copied
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const migrateFixtures = "testdata/migrate"
+
+// copyFixture copies the Go files of the fixture package into a new package of the testdata, removed at the end of
+// the spec, and returns its relative path. The package stays in the module, thus it can import di.
+func copyFixture(fixture string) string {
+	dir, err := os.MkdirTemp(migrateFixtures, "tmp-")
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(os.RemoveAll, dir)
+
+	paths, err := filepath.Glob(filepath.Join(migrateFixtures, fixture, "*.go"))
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, filepath.Base(path)), src, 0o600)).To(Succeed())
+	}
+
+	return dir
+}
+
+// expectMigratedFiles compares the files of the fixture migrated in dir with their golden files, or with the files
+// of the fixture if they have none or if they are not expected to be migrated.
+func expectMigratedFiles(fixture, dir string, migrated bool) {
+	paths, err := filepath.Glob(filepath.Join(migrateFixtures, fixture, "*.go"))
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, path := range paths {
+		actual, err := os.ReadFile(filepath.Join(dir, filepath.Base(path)))
+		Expect(err).NotTo(HaveOccurred())
+
+		expected, err := os.ReadFile(path + ".golden")
+		if !migrated || os.IsNotExist(err) {
+			expected, err = os.ReadFile(path)
+		}
+
+		Expect(err).NotTo(HaveOccurred())
+
+		if migrated && *update && !bytes.Equal(actual, expected) {
+			Expect(os.WriteFile(path+".golden", actual, 0o600)).To(Succeed())
+
+			continue
+		}
+
+		Expect(string(actual)).To(Equal(string(expected)), path)
+	}
+}
+
+var _ = Describe("migrate", func() {
+	DescribeTable("should migrate the hand-written ValueFuncs of the fixture packages",
+		func(fixture string, generated bool, expectedOutput ...string) {
+			dir := copyFixture(fixture)
+			out := new(bytes.Buffer)
+
+			Expect(migrateOptions{}.run([]string{"./" + dir}, out)).To(Succeed()) //nolint:exhaustruct
+
+			for _, expected := range expectedOutput {
+				Expect(out.String()).To(ContainSubstring(expected))
+			}
+
+			Expect(strings.Count(out.String(), "\n")).To(Equal(len(expectedOutput)), out.String())
+			expectMigratedFiles(fixture, dir, true)
+
+			_, err := os.Stat(filepath.Join(dir, "zz_generated.di.valuefunc.go"))
+			if generated {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(os.IsNotExist(err)).To(BeTrue(), "the ValueFuncs must not be generated")
+			}
+		},
+		Entry("migrated", "migrated", true,
+			"migrated Port\n",
+			"migrated Handlers\n",
+		),
+		Entry("skipped", "skipped", false,
+			"skipping port: the generated ValueFuncs are exported\n",
+			"skipping Host: the key of the Value must be the name of the func\n",
+			"skipping Timeout: the func must only have the options ...di.Option parameter\n",
+		),
+		Entry("kept-import", "keptimport", true,
+			"migrated FileSet\n",
+			"migrated File\n",
+		),
+		Entry("no-result func", "noresult", true,
+			"migrated Port\n",
+		),
+	)
+
+	It("should print the diff of the migration with --dry-run", func() {
+		dir := copyFixture("migrated")
+		out := new(bytes.Buffer)
+
+		Expect(migrateOptions{dryRun: true}.run([]string{"./" + dir}, out)).To(Succeed()) //nolint:exhaustruct
+
+		Expect(out.String()).To(ContainSubstring("-func Port(options ...di.Option) di.Value[int] {\n"))
+		Expect(out.String()).To(ContainSubstring("+// +di:valuefunc:name=port,type=int\n"))
+		Expect(out.String()).To(ContainSubstring(
+			"+// +di:valuefunc:name=handlers,container=Container,type=map[string]Handler\n"))
+
+		// the files are left unchanged.
+		expectMigratedFiles("migrated", dir, false)
+		Expect(filepath.Join(dir, "zz_generated.di.valuefunc.go")).NotTo(BeAnExistingFile())
+	})
+
+	It("should restore the migrated files if the ValueFuncs cannot be generated", func() {
+		dir := copyFixture("migrated")
+		o := migrateOptions{headerFile: filepath.Join(dir, "missing.txt")} //nolint:exhaustruct
+
+		Expect(o.run([]string{"./" + dir}, new(bytes.Buffer))).NotTo(Succeed())
+
+		expectMigratedFiles("migrated", dir, false)
+		Expect(filepath.Join(dir, "zz_generated.di.valuefunc.go")).NotTo(BeAnExistingFile())
+	})
+})
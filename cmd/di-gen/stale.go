@@ -0,0 +1,266 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/alexandremahdhaoui/di/pkg/gen"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// generatedHeader matches the comment of the files generated by di-gen, naming their generators, e.g.:
+// "// Code generated by di-gen container valuefunc. DO NOT EDIT.". The generators are not named by the files generated
+// by the previous versions of di-gen.
+var generatedHeader = regexp.MustCompile(`^// Code generated by di-gen((?: [a-z]+)*)\. DO NOT EDIT\.$`) //nolint:gochecknoglobals,lll
+
+// recorder records the paths of the files written by the output rules, so that the files they supersede are removed.
+type recorder struct {
+	mu sync.Mutex
+	// files is the set of the paths of the written files.
+	files map[string]bool
+	// unknown reports if an output rule wrote an artifact to an unknown path, e.g. to stdout.
+	unknown bool
+}
+
+// recordRule is a genall.OutputRule recording the paths of the artifacts of rule.
+type recordRule struct {
+	recorder *recorder
+	rule     genall.OutputRule
+}
+
+func newRecorder() *recorder {
+	return &recorder{files: make(map[string]bool)}
+}
+
+// outputRules wraps every output rule of rules with a recordRule.
+func (r *recorder) outputRules(rules genall.OutputRules) genall.OutputRules {
+	recorded := genall.OutputRules{
+		Default:     recordRule{recorder: r, rule: rules.Default},
+		ByGenerator: make(map[*genall.Generator]genall.OutputRule, len(rules.ByGenerator)),
+	}
+
+	for generator, rule := range rules.ByGenerator {
+		recorded.ByGenerator[generator] = recordRule{recorder: r, rule: rule}
+	}
+
+	return recorded
+}
+
+// removeStaleFiles reports the staleFiles of the roots to out, and removes them if prune is true. The files are kept
+// if an artifact was written to an unknown path, since the files superseding them cannot be told apart.
+func (r *recorder) removeStaleFiles(roots []*loader.Package, names generatedNames, prune bool, out io.Writer) error {
+	if r.unknown {
+		return nil
+	}
+
+	stale, err := staleFiles(roots, names, r.files)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range stale {
+		if !prune {
+			fmt.Fprintf(out, "%s is no longer generated, run with --prune to remove it\n", path)
+
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		fmt.Fprintf(out, "removed %s, it is no longer generated\n", path)
+	}
+
+	return nil
+}
+
+func (r recordRule) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	path, err := outputPath(r.rule, pkg, itemPath)
+
+	r.recorder.mu.Lock()
+	if err != nil {
+		r.recorder.unknown = true
+	} else {
+		r.recorder.files[path] = true
+	}
+	r.recorder.mu.Unlock()
+
+	return r.rule.Open(pkg, itemPath) //nolint:wrapcheck
+}
+
+// generatedNames matches the files the generators of a run may generate, whether with their current options or not.
+type generatedNames struct {
+	// generators are the names of the generators of the run.
+	generators []string
+	// filenames are the custom filenames of the generators, and the filename of --merge.
+	filenames []string
+}
+
+// newGeneratedNames returns the generatedNames of the generators of rawOpts, merged into the file merge if it is not
+// empty.
+func newGeneratedNames(rawOpts []string, merge string) (generatedNames, error) {
+	names := generatedNames{generators: make([]string, 0), filenames: make([]string, 0)}
+
+	for _, rawOpt := range rawOpts {
+		defn := optionsRegistry.Lookup("+"+strings.TrimPrefix(rawOpt, "+"), markers.DescribesPackage)
+		if defn == nil {
+			return names, fmt.Errorf("unknown option %q", rawOpt)
+		}
+
+		val, err := defn.Parse("+" + strings.TrimPrefix(rawOpt, "+"))
+		if err != nil {
+			return names, fmt.Errorf("unable to parse option %q: %w", rawOpt, err)
+		}
+
+		if generator, ok := val.(genall.Generator); ok {
+			names.generators = append(names.generators, defn.Name)
+
+			if filename := stringField(generator, "Filename"); filename != "" {
+				names.filenames = append(names.filenames, filename)
+			}
+		}
+	}
+
+	if merge != "" {
+		names.filenames = append(names.filenames, merge)
+	}
+
+	return names, nil
+}
+
+// owns returns true if the file name, whose header names the generators, may be generated by the generators of the
+// run. The files whose header names no generators are told apart by their names.
+func (n generatedNames) owns(name string, generators []string) bool {
+	if filepath.Ext(name) != ".go" {
+		return false
+	}
+
+	if len(generators) == 0 {
+		return n.match(name)
+	}
+
+	for _, generator := range generators {
+		if !slices.Contains(n.generators, generator) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// match returns true if name is the name of a file generated by the generators, including the files split by
+// container and the test files, e.g. zz_generated.di.valuefunc.go and zz_generated.di.valuefunc.App.go for the
+// valuefunc generator.
+func (n generatedNames) match(name string) bool {
+	if filepath.Ext(name) != ".go" {
+		return false
+	}
+
+	filenames := append(make([]string, 0, len(n.generators)+len(n.filenames)), n.filenames...)
+	for _, generator := range n.generators {
+		filenames = append(filenames, "zz_generated."+gen.DIMarkerName+"."+generator+".go")
+	}
+
+	for _, filename := range filenames {
+		base := strings.TrimSuffix(filename, ".go")
+		if name == filename || name == base+"_test.go" || strings.HasPrefix(name, base+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// staleFiles returns the sorted paths of the files generated by di-gen in the directories of the roots that names
+// owns, but that were not generated by the run, e.g. the files superseded by --merge, or the files of the packages
+// whose markers were removed.
+func staleFiles(roots []*loader.Package, names generatedNames, generated map[string]bool) ([]string, error) {
+	stale := make([]string, 0)
+	seen := make(map[string]bool, len(roots))
+
+	for _, root := range roots {
+		if len(root.GoFiles) == 0 || seen[filepath.Dir(root.GoFiles[0])] {
+			continue
+		}
+
+		dir := filepath.Dir(root.GoFiles[0])
+		seen[dir] = true
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if entry.IsDir() || generated[path] || filepath.Ext(path) != ".go" {
+				continue
+			}
+
+			generators, ok, err := generatorsOf(path)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok && names.owns(entry.Name(), generators) {
+				stale = append(stale, path)
+			}
+		}
+	}
+
+	sort.Strings(stale)
+
+	return stale, nil
+}
+
+// generatorsOf returns the generators named by the header of the Go file at path, and true if it was generated by
+// di-gen.
+func generatorsOf(path string) ([]string, bool, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err //nolint:wrapcheck
+	}
+
+	// the comments preceding the package clause are enough to tell generated files apart.
+	file, err := parser.ParseFile(token.NewFileSet(), path, src, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if match := generatedHeader.FindStringSubmatch(comment.Text); match != nil {
+				return strings.Fields(match[1]), true, nil
+			}
+		}
+	}
+
+	return nil, false, nil
+}
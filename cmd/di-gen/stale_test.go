@@ -0,0 +1,157 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+	"path/filepath"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+const (
+	diGenFile      = "// Code generated by di-gen. DO NOT EDIT.\n\npackage a\n"
+	otherGenerated = "// Code generated by controller-gen. DO NOT EDIT.\n\npackage a\n"
+)
+
+var _ = Describe("generatedNames", func() {
+	DescribeTable("should match the names of the files generated by the generators",
+		func(rawOpts []string, merge, name string, expected bool) {
+			names, err := newGeneratedNames(rawOpts, merge)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(names.match(name)).To(Equal(expected))
+		},
+		Entry("the default filename", []string{"valuefunc"}, "", "zz_generated.di.valuefunc.go", true),
+		Entry("a file split by container", []string{"valuefunc"}, "", "zz_generated.di.valuefunc.App.go", true),
+		Entry("a test file", []string{"valuefunc"}, "", "zz_generated.di.valuefunc_test.go", true),
+		Entry("a custom filename", []string{"valuefunc:filename=di_gen.go"}, "", "di_gen.go", true),
+		Entry("the default filename of a custom filename", []string{"valuefunc:filename=di_gen.go"}, "",
+			"zz_generated.di.valuefunc.go", true),
+		Entry("the merged file", []string{"valuefunc"}, "zz_generated.di.go", "zz_generated.di.go", true),
+		Entry("the file of another generator", []string{"valuefunc"}, "", "zz_generated.di.container.go", false),
+		Entry("another file", []string{"valuefunc", "container"}, "", "wiring.go", false),
+		Entry("a file that is not a Go file", []string{"valuefunc"}, "", "zz_generated.di.valuefunc.go.txt", false),
+	)
+
+	It("should fail with an unknown option", func() {
+		_, err := newGeneratedNames([]string{"unknown"}, "")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("staleFiles", func() {
+	DescribeTable("should return the files generated by di-gen that were not generated by the run",
+		func(rawOpts []string, expected ...string) {
+			dir := writeFiles(map[string]string{
+				"a.go":                             "package a\n",
+				"zz_generated.di.valuefunc.App.go": diGenFile,
+				// the files generated by the previous versions of di-gen.
+				"zz_generated.di.valuefunc.go":     diGenFile,
+				"zz_generated.di.container.go":     diGenFile,
+				"zz_generated.di.valuefunc.old.go": otherGenerated,
+				"zz_generated.di.valuefunc.x.go":   "package a\n",
+				"b/zz_generated.di.valuefunc.go":   diGenFile,
+				// the files naming their generators.
+				"di_gen.go":          "// Code generated by di-gen valuefunc. DO NOT EDIT.\n\npackage a\n",
+				"zz_generated.di.go": "// Code generated by di-gen container valuefunc. DO NOT EDIT.\n\npackage a\n",
+			})
+
+			names, err := newGeneratedNames(rawOpts, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			stale, err := staleFiles([]*loader.Package{rootIn(dir), rootIn(dir)}, names, map[string]bool{
+				filepath.Join(dir, "zz_generated.di.valuefunc.App.go"): true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for i := range expected {
+				expected[i] = filepath.Join(dir, expected[i])
+			}
+
+			Expect(stale).To(Equal(expected))
+		},
+		Entry("a generator", []string{"valuefunc:splitByContainer=true"},
+			"di_gen.go", "zz_generated.di.valuefunc.go",
+		),
+		Entry("several generators", []string{"valuefunc:splitByContainer=true", "container"},
+			"di_gen.go", "zz_generated.di.container.go", "zz_generated.di.go", "zz_generated.di.valuefunc.go",
+		),
+		Entry("another generator", []string{"provide"}),
+	)
+})
+
+var _ = Describe("recorder", func() {
+	var (
+		dir   string
+		names generatedNames
+	)
+
+	BeforeEach(func() {
+		dir = writeFiles(map[string]string{
+			"a.go":                         "package a\n",
+			"zz_generated.di.valuefunc.go": diGenFile,
+		})
+
+		var err error
+		names, err = newGeneratedNames([]string{"valuefunc"}, "zz_generated.di.go")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	// open opens the item of the root in dir with the recordRule of rule.
+	open := func(r *recorder, rule genall.OutputRule, itemPath string) {
+		out, err := r.outputRules(genall.OutputRules{Default: rule}).Default.Open(rootIn(dir), itemPath) //nolint:exhaustruct
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.Close()).To(Succeed())
+	}
+
+	It("should report the files that are no longer generated without removing them", func() {
+		r := newRecorder()
+		open(r, genall.OutputArtifacts{}, "zz_generated.di.go") //nolint:exhaustruct
+
+		out := new(bytes.Buffer)
+		Expect(r.removeStaleFiles([]*loader.Package{rootIn(dir)}, names, false, out)).To(Succeed())
+
+		stale := filepath.Join(dir, "zz_generated.di.valuefunc.go")
+		Expect(stale).To(BeAnExistingFile())
+		Expect(out.String()).To(Equal(stale + " is no longer generated, run with --prune to remove it\n"))
+	})
+
+	It("should remove the files that are no longer generated with prune", func() {
+		r := newRecorder()
+		open(r, genall.OutputArtifacts{}, "zz_generated.di.go") //nolint:exhaustruct
+
+		out := new(bytes.Buffer)
+		Expect(r.removeStaleFiles([]*loader.Package{rootIn(dir)}, names, true, out)).To(Succeed())
+
+		stale := filepath.Join(dir, "zz_generated.di.valuefunc.go")
+		Expect(stale).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(dir, "zz_generated.di.go")).To(BeAnExistingFile())
+		Expect(out.String()).To(Equal("removed " + stale + ", it is no longer generated\n"))
+	})
+
+	It("should keep the files if an artifact is written to an unknown path", func() {
+		r := newRecorder()
+		open(r, genall.OutputToNothing, "zz_generated.di.go")
+
+		Expect(r.removeStaleFiles([]*loader.Package{rootIn(dir)}, names, true, new(bytes.Buffer))).To(Succeed())
+
+		Expect(filepath.Join(dir, "zz_generated.di.valuefunc.go")).To(BeAnExistingFile())
+	})
+})
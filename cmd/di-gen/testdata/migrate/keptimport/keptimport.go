@@ -0,0 +1,35 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keptimport
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/alexandremahdhaoui/di"
+)
+
+// Position is the position of the parsed file.
+var Position token.Pos //nolint:gochecknoglobals
+
+func FileSet(options ...di.Option) di.Value[*token.FileSet] {
+	return di.MustWithOptions[*token.FileSet](di.DefaultContainer, "FileSet", options...)
+}
+
+func File(options ...di.Option) di.Value[*ast.File] {
+	return di.MustWithOptions[*ast.File](di.DefaultContainer, "File", options...)
+}
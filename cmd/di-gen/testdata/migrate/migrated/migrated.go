@@ -0,0 +1,36 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrated
+
+import (
+	"github.com/alexandremahdhaoui/di"
+)
+
+// Container is the container of the server.
+var Container = di.New("Container") //nolint:gochecknoglobals
+
+// Handler handles the requests of the server.
+type Handler struct{}
+
+// Port is the port of the server.
+func Port(options ...di.Option) di.Value[int] {
+	return di.MustWithOptions[int](di.DefaultContainer, "Port", options...)
+}
+
+func Handlers(options ...di.Option) di.Value[map[string]Handler] {
+	return di.MustWithOptions[map[string]Handler](Container, "Handlers", options...)
+}
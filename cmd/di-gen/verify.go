@@ -0,0 +1,184 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sort"
+	"sync"
+)
+
+var errStaleFiles = errors.New("generated files are stale, run di-gen to update them")
+
+// verifier regenerates the files into memory, then compares them with the files on disk.
+type verifier struct {
+	mu sync.Mutex
+	// files maps the path of the generated files to their expected content.
+	files map[string][]byte
+}
+
+// verifyRule is a genall.OutputRule keeping the artifacts of rule in memory, instead of writing them.
+type verifyRule struct {
+	verifier *verifier
+	rule     genall.OutputRule
+}
+
+type verifyFile struct {
+	bytes.Buffer
+	verifier *verifier
+	path     string
+}
+
+func newVerifier() *verifier {
+	return &verifier{files: make(map[string][]byte)}
+}
+
+// outputRules wraps every output rule of rules with a verifyRule.
+func (v *verifier) outputRules(rules genall.OutputRules) genall.OutputRules {
+	verified := genall.OutputRules{
+		Default:     verifyRule{verifier: v, rule: rules.Default},
+		ByGenerator: make(map[*genall.Generator]genall.OutputRule, len(rules.ByGenerator)),
+	}
+
+	for generator, rule := range rules.ByGenerator {
+		verified.ByGenerator[generator] = verifyRule{verifier: v, rule: rule}
+	}
+
+	return verified
+}
+
+// verify writes the unified diff of the stale files to w, and returns errStaleFiles if any file is stale. The
+// superseded files are stale files that are no longer generated, see staleFiles.
+func (v *verifier) verify(w io.Writer, superseded []string) error {
+	paths := make([]string, 0, len(v.files))
+	for path := range v.files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	stale := false
+
+	for _, path := range paths {
+		actual, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err //nolint:wrapcheck
+		}
+
+		if bytes.Equal(actual, v.files[path]) {
+			continue
+		}
+
+		stale = true
+
+		if _, err := io.WriteString(w, unifiedDiff(path, string(actual), string(v.files[path]))); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	for _, path := range superseded {
+		actual, err := os.ReadFile(path)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		stale = true
+
+		if _, err := fmt.Fprintf(w, "%s is no longer generated, remove it\n%s", path,
+			unifiedDiff(path, string(actual), "")); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	if stale {
+		return errStaleFiles
+	}
+
+	return nil
+}
+
+// generated returns the set of the paths of the generated files.
+func (v *verifier) generated() map[string]bool {
+	generated := make(map[string]bool, len(v.files))
+	for path := range v.files {
+		generated[path] = true
+	}
+
+	return generated
+}
+
+// write writes the files generated into memory to disk.
+func (v *verifier) write() error {
+	for _, path := range sortedPaths(v.files) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //nolint:gomnd
+			return err //nolint:wrapcheck
+		}
+
+		if err := os.WriteFile(path, v.files[path], 0o644); err != nil { //nolint:gomnd,gosec
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+func (r verifyRule) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	path, err := outputPath(r.rule, pkg, itemPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &verifyFile{verifier: r.verifier, path: path}, nil
+}
+
+func (f *verifyFile) Close() error {
+	f.verifier.mu.Lock()
+	defer f.verifier.mu.Unlock()
+
+	f.verifier.files[f.path] = f.Bytes()
+
+	return nil
+}
+
+// outputPath returns the path of the file that rule would write, mirroring genall.OutputArtifacts &
+// genall.OutputToDirectory.
+func outputPath(rule genall.OutputRule, pkg *loader.Package, itemPath string) (string, error) {
+	switch r := rule.(type) {
+	case genall.OutputToDirectory:
+		return filepath.Join(string(r), itemPath), nil
+	case genall.OutputArtifacts:
+		switch {
+		case pkg == nil:
+			return filepath.Join(string(r.Config), itemPath), nil
+		case r.Code != "":
+			return filepath.Join(string(r.Code), itemPath), nil
+		case len(pkg.CompiledGoFiles) == 0:
+			return "", fmt.Errorf("cannot output to a package with no path on disk")
+		default:
+			return filepath.Join(filepath.Dir(pkg.CompiledGoFiles[0]), itemPath), nil
+		}
+	default:
+		return "", fmt.Errorf("cannot verify the output rule %T", rule)
+	}
+}
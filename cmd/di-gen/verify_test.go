@@ -0,0 +1,133 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+	"golang.org/x/tools/go/packages"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// rootIn returns a root package whose files are in dir.
+func rootIn(dir string) *loader.Package {
+	return &loader.Package{Package: &packages.Package{ //nolint:exhaustruct
+		GoFiles:         []string{filepath.Join(dir, "a.go")},
+		CompiledGoFiles: []string{filepath.Join(dir, "a.go")},
+	}}
+}
+
+var _ = Describe("verifier", func() {
+	var (
+		dir string
+		v   *verifier
+	)
+
+	BeforeEach(func() {
+		dir = writeFiles(map[string]string{
+			"up_to_date.go": "package a\n",
+			"stale.go":      "package a\n\nvar A = 1\n",
+		})
+		v = newVerifier()
+	})
+
+	// generate generates the files by item path into memory.
+	generate := func(files map[string]string) {
+		rule := v.outputRules(genall.OutputRules{Default: genall.OutputArtifacts{}}).Default //nolint:exhaustruct
+
+		for itemPath, content := range files {
+			out, err := rule.Open(rootIn(dir), itemPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.Write([]byte(content))).To(Equal(len(content)))
+			Expect(out.Close()).To(Succeed())
+		}
+	}
+
+	It("should not report the up-to-date files", func() {
+		generate(map[string]string{"up_to_date.go": "package a\n"})
+
+		out := new(bytes.Buffer)
+		Expect(v.verify(out, nil)).To(Succeed())
+		Expect(out.String()).To(BeEmpty())
+	})
+
+	It("should report the diff of the stale and missing files", func() {
+		generate(map[string]string{
+			"up_to_date.go": "package a\n",
+			"stale.go":      "package a\n\nvar A = 2\n",
+			"missing.go":    "package a\n",
+		})
+
+		out := new(bytes.Buffer)
+		Expect(v.verify(out, nil)).To(MatchError(errStaleFiles))
+
+		missing, stale := filepath.Join(dir, "missing.go"), filepath.Join(dir, "stale.go")
+		Expect(out.String()).To(Equal(
+			"--- " + missing + "\n+++ " + missing + " (generated)\n@@ -0,0 +1,1 @@\n+package a\n" +
+				"--- " + stale + "\n+++ " + stale + " (generated)\n@@ -1,3 +1,3 @@\n package a\n \n-var A = 1\n+var A = 2\n",
+		))
+
+		// the files are not written.
+		Expect(filepath.Join(dir, "missing.go")).NotTo(BeAnExistingFile())
+	})
+
+	It("should report the superseded files", func() {
+		generate(map[string]string{"up_to_date.go": "package a\n"})
+
+		out := new(bytes.Buffer)
+		superseded := filepath.Join(dir, "stale.go")
+		Expect(v.verify(out, []string{superseded})).To(MatchError(errStaleFiles))
+
+		Expect(out.String()).To(Equal(superseded + " is no longer generated, remove it\n" +
+			"--- " + superseded + "\n+++ " + superseded + " (generated)\n@@ -1,3 +0,0 @@\n-package a\n-\n-var A = 1\n"))
+	})
+
+	It("should write the generated files", func() {
+		generate(map[string]string{"stale.go": "package a\n\nvar A = 2\n", "missing.go": "package a\n"})
+
+		Expect(v.generated()).To(Equal(map[string]bool{
+			filepath.Join(dir, "stale.go"):   true,
+			filepath.Join(dir, "missing.go"): true,
+		}))
+		Expect(v.write()).To(Succeed())
+
+		Expect(os.ReadFile(filepath.Join(dir, "stale.go"))).To(Equal([]byte("package a\n\nvar A = 2\n")))
+		Expect(os.ReadFile(filepath.Join(dir, "missing.go"))).To(Equal([]byte("package a\n")))
+		Expect(v.verify(new(bytes.Buffer), nil)).To(Succeed())
+	})
+})
+
+var _ = Describe("outputPath", func() {
+	DescribeTable("should return the path of the file written by the output rule",
+		func(rule genall.OutputRule, pkg *loader.Package, expected string) {
+			Expect(outputPath(rule, pkg, "file.go")).To(Equal(expected))
+		},
+		Entry("the directory of the package", genall.OutputArtifacts{}, rootIn("pkg"), "pkg/file.go"),    //nolint:exhaustruct
+		Entry("the code directory", genall.OutputArtifacts{Code: "code"}, rootIn("pkg"), "code/file.go"), //nolint:exhaustruct
+		Entry("the config directory", genall.OutputArtifacts{Config: "config"}, nil, "config/file.go"),   //nolint:exhaustruct
+		Entry("a directory", genall.OutputToDirectory("dir"), rootIn("pkg"), "dir/file.go"),
+	)
+
+	It("should fail with the output rules that do not write files", func() {
+		_, err := outputPath(genall.OutputToStdout, rootIn("pkg"), "file.go")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,300 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alexandremahdhaoui/di/pkg/gen"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"golang.org/x/tools/go/packages"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sort"
+	"strings"
+	"time"
+)
+
+// watcher polls the Go files of the packages of rawOpts, and regenerates the packages whose +di markers changed.
+//
+// The packages importing a changed package are regenerated too, since their ValueFuncs may belong to the
+// di.Containers of the changed package. The packages are listed when the watcher starts, thus the packages created
+// afterwards are not watched, and the imports added afterwards are ignored. The generated files and the test files
+// are not watched.
+type watcher struct {
+	rawOpts  []string
+	interval time.Duration
+	out      io.Writer
+	errOut   io.Writer
+	// files maps the paths of the watched Go files to their state at the last poll.
+	files map[string]watchedFile
+}
+
+type watchedFile struct {
+	modTime time.Time
+	size    int64
+	// markers reports if the file contains +di markers.
+	markers bool
+}
+
+// generateFunc runs the generators of rawOpts, restricted to the packages of changedDirs if it is not nil.
+type generateFunc func(rawOpts []string, changedDirs map[string]bool) error
+
+func newWatcher(rawOpts []string, interval time.Duration, out, errOut io.Writer) *watcher {
+	return &watcher{rawOpts: rawOpts, interval: interval, out: out, errOut: errOut}
+}
+
+// watch generates the packages, then regenerates the packages whose +di markers changed at each poll, until ctx is
+// done or the process is interrupted. The errors of the generation are printed, they do not stop the watcher.
+func (w *watcher) watch(ctx context.Context, generate generateFunc) error {
+	if w.interval <= 0 {
+		return fmt.Errorf("invalid --watch-interval %s, expected a positive duration", w.interval)
+	}
+
+	paths, aggregate, err := watchedPaths(w.rawOpts)
+	if err != nil {
+		return err
+	}
+
+	dirs, importers, err := packageDirs(paths)
+	if err != nil {
+		return noUsageError{err}
+	}
+
+	// the options are invalid if the first generation fails with a usage error.
+	if err := generate(w.rawOpts, nil); err != nil {
+		var noUsage noUsageError
+		if !errors.As(err, &noUsage) {
+			return err
+		}
+
+		w.report(err)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	// the first poll records the state of the files, it cannot detect changes.
+	w.poll(dirs)
+	fmt.Fprintf(w.out, "watching %d packages, press Ctrl+C to stop\n", len(dirs))
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		changedDirs := w.poll(dirs)
+		if len(changedDirs) == 0 {
+			continue
+		}
+
+		for dir := range changedDirs {
+			for _, importer := range importers[dir] {
+				changedDirs[importer] = true
+			}
+		}
+
+		fmt.Fprintf(w.out, "%s regenerating %s\n", time.Now().Format(time.TimeOnly), w.describe(changedDirs))
+
+		// the aggregate generators describe all the packages, thus they cannot run against the changed packages only.
+		if aggregate {
+			changedDirs = nil
+		}
+
+		if err := generate(w.rawOpts, changedDirs); err != nil {
+			w.report(err)
+		}
+	}
+}
+
+// poll updates the state of the Go files of dirs, and returns the directories of the files whose +di markers may
+// have changed, i.e. the files containing markers before or after their modification.
+func (w *watcher) poll(dirs []string) map[string]bool {
+	first := w.files == nil
+	files := make(map[string]watchedFile, len(w.files))
+	changedDirs := make(map[string]bool)
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			w.report(err)
+
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+
+			info, err := entry.Info()
+			if err != nil {
+				// the file was removed since the directory was read.
+				continue
+			}
+
+			previous, ok := w.files[path]
+			if ok && previous.modTime.Equal(info.ModTime()) && previous.size == info.Size() {
+				files[path] = previous
+
+				continue
+			}
+
+			file := watchedFile{modTime: info.ModTime(), size: info.Size(), markers: hasMarkers(path)}
+			files[path] = file
+
+			if !first && (file.markers || previous.markers) {
+				changedDirs[dir] = true
+			}
+		}
+	}
+
+	// the removed files containing markers.
+	for path, previous := range w.files {
+		if _, ok := files[path]; !ok && previous.markers {
+			changedDirs[filepath.Dir(path)] = true
+		}
+	}
+
+	w.files = files
+
+	return changedDirs
+}
+
+// report prints err without stopping the watcher.
+func (w *watcher) report(err error) {
+	fmt.Fprintf(w.errOut, "Error: %v\n", err)
+}
+
+// describe returns the sorted directories of dirs, relative to the working directory when possible.
+func (w *watcher) describe(dirs map[string]bool) string {
+	wd, _ := os.Getwd()
+	described := make([]string, 0, len(dirs))
+
+	for dir := range dirs {
+		if rel, err := filepath.Rel(wd, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			dir = "./" + filepath.ToSlash(rel)
+		}
+
+		described = append(described, dir)
+	}
+
+	sort.Strings(described)
+
+	return strings.Join(described, ", ")
+}
+
+// hasMarkers reports if the Go file at path contains +di markers. Generated files are reported to have none, so that
+// writing them does not trigger a new generation.
+func hasMarkers(path string) bool {
+	src, err := os.ReadFile(path)
+	if err != nil || !bytes.Contains(src, []byte("+"+gen.DIMarkerName+":")) {
+		return false
+	}
+
+	// the comments preceding the package clause are enough to tell generated files apart.
+	file, err := parser.ParseFile(token.NewFileSet(), path, src, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		// the file may be saved in an invalid state, it is still regenerated to report the error.
+		return true
+	}
+
+	return !ast.IsGenerated(file)
+}
+
+// watchedPaths returns the paths of rawOpts, and reports if rawOpts contain an aggregate generator.
+func watchedPaths(rawOpts []string) ([]string, bool, error) {
+	paths := make([]string, 0)
+	aggregate := false
+
+	for _, rawOpt := range rawOpts {
+		defn := optionsRegistry.Lookup("+"+strings.TrimPrefix(rawOpt, "+"), markers.DescribesPackage)
+		if defn == nil {
+			return nil, false, fmt.Errorf("unknown option %q", rawOpt)
+		}
+
+		val, err := defn.Parse("+" + strings.TrimPrefix(rawOpt, "+"))
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to parse option %q: %w", rawOpt, err)
+		}
+
+		switch val := val.(type) {
+		case genall.InputPaths:
+			paths = append(paths, val...)
+		case genall.Generator:
+			aggregate = aggregate || aggregateGenerators[defn.Name]
+		}
+	}
+
+	return paths, aggregate, nil
+}
+
+// packageDirs returns the sorted absolute directories of the packages of paths, and the directories of the packages
+// of paths importing them by directory.
+func packageDirs(paths []string) ([]string, map[string][]string, error) {
+	pkgs, err := packages.Load(&packages.Config{ //nolint:exhaustruct
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports,
+	}, paths...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot list the packages: %w", err)
+	}
+
+	dirs := make([]string, 0, len(pkgs))
+	dirsByPath := make(map[string]string, len(pkgs))
+
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) > 0 {
+			dirs = append(dirs, filepath.Dir(pkg.GoFiles[0]))
+			dirsByPath[pkg.PkgPath] = filepath.Dir(pkg.GoFiles[0])
+		}
+	}
+
+	importers := make(map[string][]string)
+
+	for _, pkg := range pkgs {
+		importer, ok := dirsByPath[pkg.PkgPath]
+		if !ok {
+			continue
+		}
+
+		for path := range pkg.Imports {
+			if dir, ok := dirsByPath[path]; ok {
+				importers[dir] = append(importers[dir], importer)
+			}
+		}
+	}
+
+	sort.Strings(dirs)
+
+	return dirs, importers, nil
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+	"github.com/onsi/gomega/gbytes"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("watch", func() {
+	DescribeTable("hasMarkers",
+		func(content string, expected bool) {
+			dir := writeFiles(map[string]string{"a.go": content})
+			Expect(hasMarkers(filepath.Join(dir, "a.go"))).To(Equal(expected))
+		},
+		Entry("a file with markers", "// +di:container:name=app\npackage a\n", true),
+		Entry("a file without markers", "package a\n", false),
+		Entry("a generated file", "// Code generated by di-gen valuefunc. DO NOT EDIT.\n\n// +di:container:name=app\n"+
+			"package a\n", false),
+		Entry("an invalid file with markers", "// +di:container:name=app\npackage\n", true),
+	)
+
+	DescribeTable("watchedPaths",
+		func(rawOpts []string, paths []string, aggregate bool) {
+			gotPaths, gotAggregate, err := watchedPaths(rawOpts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gotPaths).To(Equal(paths))
+			Expect(gotAggregate).To(Equal(aggregate))
+		},
+		Entry("the paths of the options", []string{"valuefunc", "paths=./a;./b", "+paths=./c"},
+			[]string{"./a", "./b", "./c"}, false),
+		Entry("an aggregate generator", []string{"container", "manifest", "paths=./..."}, []string{"./..."}, true),
+		Entry("no paths", []string{"docs:format=html"}, []string{}, true),
+	)
+
+	It("should report the unknown options", func() {
+		_, _, err := watchedPaths([]string{"valuefunc", "unknown"})
+		Expect(err).To(MatchError(`unknown option "unknown"`))
+	})
+
+	Describe("poll", func() {
+		var (
+			dir string
+			w   *watcher
+		)
+
+		// touch writes content to the file name of dir, with a modification time that cannot be the one of the
+		// previous write.
+		touch := func(name, content string) {
+			path := filepath.Join(dir, name)
+			Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+
+			modTime := time.Now().Add(time.Hour)
+			if previous, ok := w.files[path]; ok {
+				modTime = previous.modTime.Add(time.Second)
+			}
+
+			Expect(os.Chtimes(path, modTime, modTime)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			dir = writeFiles(map[string]string{
+				"markers.go":      "// +di:container:name=app\npackage a\n",
+				"plain.go":        "package a\n",
+				"markers_test.go": "// +di:container:name=app\npackage a\n",
+			})
+			w = newWatcher(nil, time.Second, &bytes.Buffer{}, &bytes.Buffer{})
+
+			By("recording the state of the files at the first poll")
+			Expect(w.poll([]string{dir})).To(BeEmpty())
+		})
+
+		It("should report the files whose markers may have changed", func() {
+			touch("plain.go", "package a\n\nvar A int\n")
+			Expect(w.poll([]string{dir})).To(BeEmpty())
+
+			touch("markers.go", "// +di:container:name=other\npackage a\n")
+			Expect(w.poll([]string{dir})).To(Equal(map[string]bool{dir: true}))
+
+			By("ignoring the unchanged files")
+			Expect(w.poll([]string{dir})).To(BeEmpty())
+
+			By("reporting the files whose markers were removed")
+			touch("markers.go", "package a\n")
+			Expect(w.poll([]string{dir})).To(Equal(map[string]bool{dir: true}))
+
+			By("reporting the new files with markers")
+			touch("new.go", "// +di:container:name=app\npackage a\n")
+			Expect(w.poll([]string{dir})).To(Equal(map[string]bool{dir: true}))
+
+			By("reporting the removed files with markers")
+			Expect(os.Remove(filepath.Join(dir, "new.go"))).To(Succeed())
+			Expect(w.poll([]string{dir})).To(Equal(map[string]bool{dir: true}))
+
+			By("ignoring the test files")
+			touch("markers_test.go", "// +di:container:name=other\npackage a\n")
+			Expect(w.poll([]string{dir})).To(BeEmpty())
+		})
+
+		It("should report the files with markers of the unreadable directories as removed", func() {
+			errOut := &bytes.Buffer{}
+			w.errOut = errOut
+
+			Expect(os.RemoveAll(dir)).To(Succeed())
+			Expect(w.poll([]string{dir})).To(Equal(map[string]bool{dir: true}))
+			Expect(errOut.String()).To(HavePrefix("Error: "))
+		})
+	})
+
+	It("should regenerate the changed packages and their importers", func() {
+		dir := writeFiles(map[string]string{
+			"b/b.go": "// +di:container:name=b,exported=true\npackage b\n",
+			"c/c.go": "// +di:container:name=c\npackage c\n",
+		})
+		Expect(os.MkdirAll(filepath.Join(dir, "a"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "a", "a.go"),
+			[]byte("package a\n\nimport _ \""+modulePath+filepath.ToSlash(dir)+"/b\"\n"), 0o600)).To(Succeed())
+
+		abs, err := filepath.Abs(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		generated := make(chan map[string]bool, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		out := gbytes.NewBuffer()
+
+		go func() {
+			defer GinkgoRecover()
+
+			done <- newWatcher([]string{"valuefunc", "paths=./" + dir + "/..."}, 10*time.Millisecond, out,
+				&bytes.Buffer{}).watch(ctx, func(_ []string, changedDirs map[string]bool) error {
+				generated <- changedDirs
+
+				return nil
+			})
+		}()
+
+		By("generating all the packages first")
+		Eventually(generated).Should(Receive(BeNil()))
+
+		// the watcher records the state of the files after the first generation.
+		Eventually(out).Should(gbytes.Say("watching 3 packages"))
+
+		path := filepath.Join(dir, "b", "b.go")
+		Expect(os.WriteFile(path, []byte("// +di:container:name=other,exported=true\npackage b\n"), 0o600)).To(Succeed())
+
+		modTime := time.Now().Add(time.Hour)
+		Expect(os.Chtimes(path, modTime, modTime)).To(Succeed())
+
+		Eventually(generated).Should(Receive(Equal(map[string]bool{
+			filepath.Join(abs, "a"): true,
+			filepath.Join(abs, "b"): true,
+		})))
+
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+})
@@ -18,6 +18,12 @@ package di
 
 import (
 	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
 )
 
 const (
@@ -39,19 +45,44 @@ var DefaultContainer = New(DefaultContainerName) //nolint:unused
 
 type (
 	Container interface {
-		get(key string) (pointer, bool)
-		set(key string, ptr pointer) error
+		get(key string) (pointer, error)
+		set(key string, ptr pointer, typ reflect.Type) error
+		provide(key string, fn func() error)
+		typeOf(key string) (reflect.Type, bool)
+		keys() []string
+		dependencies(key string) []string
+		notify(kind EventKind, key string, typ reflect.Type, err error)
+
+		install(m Module)
+		modules() []Module
+		// checkpoint returns a func removing the Values set and the Modules installed since the checkpoint.
+		checkpoint() (rollback func())
 
 		// Build an "immutable" container
 		Build()
 		Name() string
+		// SetTracer sets the Tracer notified when a provider constructs a Value.
+		SetTracer(tracer Tracer)
+		// AddObserver adds an Observer receiving the Events emitted by the container.
+		AddObserver(observer Observer)
 	}
 
 	container struct {
 		built    map[string]pointer
 		immature map[string]pointer
+		types    map[string]reflect.Type
 		name     string
 		state    containerState
+
+		// providers holds the constructors of the Values that are not resolved yet.
+		providers map[string]func() error
+		// resolving is the stack of the keys of the Values being constructed.
+		resolving []string
+		// edges maps the key of a constructed Value to the keys of the Values accessed during its construction.
+		edges     map[string]map[string]struct{}
+		installed []Module
+		tracer    Tracer
+		observers []Observer
 	}
 
 	pointer *any
@@ -60,49 +91,261 @@ type (
 	containerState int
 )
 
-func (c *container) get(key string) (pointer, bool) {
-	if c.state == immatureContainerState {
-		v, ok := c.immature[key]
+func (c *container) get(key string) (pointer, error) {
+	c.recordDependency(key)
+
+	if c.state == builtContainerState {
+		v, ok := c.built[key]
+		if !ok {
+			return nil, c.fail(key, ErrGetItemWithKey(key, c.name))
+		}
+
+		return v, nil
+	}
+
+	v, ok := c.immature[key]
+	if !ok {
+		return nil, c.fail(key, ErrGetItemWithKey(key, c.name))
+	}
+
+	if err := c.resolve(key); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// resolve calls the provider of the Value identified by key, if that Value was not constructed yet.
+func (c *container) resolve(key string) error {
+	fn, ok := c.providers[key]
+	if !ok {
+		return nil
+	}
 
-		return v, ok
+	for _, k := range c.resolving {
+		if k == key {
+			return c.fail(key, formatErr(ErrResolvingValue, fmt.Sprintf("circular dependency on %q in container %q: %s",
+				key, c.name, strings.Join(append(c.resolving, key), " -> "))))
+		}
 	}
 
-	v, ok := c.built[key]
+	span := c.startSpan(key)
+	err := c.construct(key, fn)
+	span.End(err)
+
+	if err != nil {
+		return c.fail(key, formatErr(ErrResolvingValue,
+			fmt.Sprintf("cannot construct %q in container %q", key, c.name), err.Error()))
+	}
 
-	return v, ok
+	delete(c.providers, key)
+	c.notify(ValueResolvedEvent, key, c.types[key], nil)
+
+	return nil
+}
+
+// construct calls the provider fn of the Value identified by key, while key is on top of the resolving stack.
+func (c *container) construct(key string, fn func() error) error {
+	c.resolving = append(c.resolving, key)
+	defer func() { c.resolving = c.resolving[:len(c.resolving)-1] }()
+
+	return fn()
 }
 
-func (c *container) set(key string, ptr pointer) error {
+func (c *container) set(key string, ptr pointer, typ reflect.Type) error {
 	if c.state == builtContainerState {
-		return formatErr(fmt.Sprintf("error while setting %q: container %q is immutable", key, c.name))
+		return c.fail(key, formatErr(fmt.Sprintf("error while setting %q: container %q is immutable", key, c.name)))
+	}
+
+	if _, ok := c.immature[key]; ok {
+		c.notify(ValueOverriddenEvent, key, typ, nil)
 	}
 
 	c.immature[key] = ptr
+	c.types[key] = typ
+	delete(c.providers, key)
 
 	return nil
 }
 
+func (c *container) provide(key string, fn func() error) {
+	c.providers[key] = fn
+}
+
+// typeOf returns the type T of the Value[T] stored under the given key.
+func (c *container) typeOf(key string) (reflect.Type, bool) {
+	typ, ok := c.types[key]
+
+	return typ, ok
+}
+
+func (c *container) startSpan(key string) Span { //nolint:ireturn
+	if c.tracer == nil {
+		return noopSpan{}
+	}
+
+	r := Resolution{
+		Container: c.name,
+		Key:       key,
+		Goroutine: goroutineID(),
+		Start:     time.Now(),
+	}
+
+	if len(c.resolving) > 0 {
+		r.Parent = c.resolving[len(c.resolving)-1]
+	}
+
+	return c.tracer.StartResolution(r)
+}
+
+// recordDependency records that key was accessed during the construction of the Value on top of the resolving stack.
+func (c *container) recordDependency(key string) {
+	if _, ok := c.types[key]; !ok || len(c.resolving) == 0 {
+		return
+	}
+
+	from := c.resolving[len(c.resolving)-1]
+	if _, ok := c.edges[from]; !ok {
+		c.edges[from] = make(map[string]struct{})
+	}
+
+	c.edges[from][key] = struct{}{}
+}
+
+// dependencies returns the sorted keys of the Values accessed during the construction of the Value identified by key.
+func (c *container) dependencies(key string) []string {
+	sl := make([]string, 0, len(c.edges[key]))
+	for k := range c.edges[key] {
+		sl = append(sl, k)
+	}
+
+	sort.Strings(sl)
+
+	return sl
+}
+
+func (c *container) notify(kind EventKind, key string, typ reflect.Type, err error) {
+	if len(c.observers) == 0 {
+		return
+	}
+
+	e := Event{
+		Kind:      kind,
+		Container: c.name,
+		Key:       key,
+		Type:      typ,
+		Err:       err,
+		Time:      time.Now(),
+	}
+
+	for _, observer := range c.observers {
+		observer.OnEvent(e)
+	}
+}
+
+// fail emits an ErrorEvent and returns err.
+func (c *container) fail(key string, err error) error {
+	c.notify(ErrorEvent, key, c.types[key], err)
+
+	return err
+}
+
+func (c *container) install(m Module) {
+	c.installed = append(c.installed, m)
+}
+
+func (c *container) modules() []Module {
+	return c.installed
+}
+
+func (c *container) checkpoint() func() {
+	immature := maps.Clone(c.immature)
+	types := maps.Clone(c.types)
+	providers := maps.Clone(c.providers)
+	installed := len(c.installed)
+
+	return func() {
+		for key, ptr := range c.immature {
+			previous, ok := immature[key]
+
+			switch {
+			case !ok:
+				delete(c.immature, key)
+				delete(c.types, key)
+				delete(c.providers, key)
+				delete(c.edges, key)
+			case previous != ptr:
+				// the Value was overridden since the checkpoint.
+				c.immature[key] = previous
+				c.types[key] = types[key]
+				delete(c.providers, key)
+
+				if fn, ok := providers[key]; ok {
+					c.providers[key] = fn
+				}
+			}
+		}
+
+		c.installed = c.installed[:installed]
+	}
+}
+
+// keys returns the sorted keys of all Values held by the container.
+func (c *container) keys() []string {
+	sl := make([]string, 0, len(c.types))
+	for key := range c.types {
+		sl = append(sl, key)
+	}
+
+	sort.Strings(sl)
+
+	return sl
+}
+
+// Build resolves the Values that are not constructed yet, and makes the container immutable.
+// Build panics if a provider fails to construct its Value.
 func (c *container) Build() {
 	if c.state == builtContainerState {
 		return
 	}
 
+	c.notify(BuildStartedEvent, "", nil, nil)
+
+	for _, key := range c.keys() {
+		if err := c.resolve(key); err != nil {
+			panic(err)
+		}
+	}
+
 	for key, ptr := range c.immature {
-		newPtr := new(any)
-		*newPtr = *ptr
-		c.built[key] = newPtr
+		// ptr points to a T, thus we copy the underlying T instead of dereferencing ptr as an any.
+		typ := c.types[key]
+		newPtr := reflect.New(typ)
+		newPtr.Elem().Set(reflect.NewAt(typ, unsafe.Pointer(ptr)).Elem())
+		c.built[key] = (*any)(newPtr.UnsafePointer())
 
 		delete(c.immature, key)
 	}
 
 	c.state = builtContainerState
 	c.immature = nil
+	c.providers = nil
+
+	c.notify(BuildFinishedEvent, "", nil, nil)
 }
 
 func (c *container) Name() string {
 	return c.name
 }
 
+func (c *container) SetTracer(tracer Tracer) {
+	c.tracer = tracer
+}
+
+func (c *container) AddObserver(observer Observer) {
+	c.observers = append(c.observers, observer)
+}
+
 func New(name string) Container { //nolint:ireturn
 	if name == "" {
 		panic("a name is required to create a new container")
@@ -111,24 +354,30 @@ func New(name string) Container { //nolint:ireturn
 	return &container{
 		built:    make(map[string]pointer),
 		immature: make(map[string]pointer),
+		types:    make(map[string]reflect.Type),
 		name:     name,
 		state:    immatureContainerState,
+
+		providers: make(map[string]func() error),
+		edges:     make(map[string]map[string]struct{}),
 	}
 }
 
 func InitializeValue[T any](c Container, key string) (Value[T], error) {
 	v := NewValue[T](key, nil)
-	if err := c.set(key, v.pointer()); err != nil {
+	if err := c.set(key, v.pointer(), typeOf[T]()); err != nil {
 		return nil, err
 	}
 
+	c.notify(ValueInitializedEvent, key, typeOf[T](), nil)
+
 	return v, nil
 }
 
 func Get[T any](c Container, key string) (Value[T], error) {
-	ptr, ok := c.get(key)
-	if !ok {
-		return nil, ErrGetItemWithKey(key, c.Name())
+	ptr, err := c.get(key)
+	if err != nil {
+		return nil, err
 	}
 
 	converted, err := ConvertPointer[T](ptr)
@@ -139,6 +388,22 @@ func Get[T any](c Container, key string) (Value[T], error) {
 	return NewValue[T](key, converted), err
 }
 
+// Check returns an error if no Value[T] is registered under key in the Container, or if the Value registered under key
+// is not a Value[T]. Unlike Get, Check does not call the provider of the Value.
+func Check[T any](c Container, key string) error {
+	typ, ok := c.typeOf(key)
+	if !ok {
+		return ErrGetItemWithKey(key, c.Name())
+	}
+
+	if typ != typeOf[T]() {
+		return formatErr(ErrAssertingType,
+			fmt.Sprintf("Value %q in container %q is a Value[%s], not a Value[%s]", key, c.Name(), typ, typeOf[T]()))
+	}
+
+	return nil
+}
+
 func Must[T any](c Container, key string) Value[T] {
 	v, err := Get[T](c, key)
 	if err != nil {
@@ -160,8 +425,8 @@ func MustWithOptions[T any](c Container, key string, option ...Option) Value[T]
 	}
 
 	// Set -- implicit or explicit resolves to Set
-	v := NewValue[T](key, nil)
-	if err := Set(c, v); err != nil {
+	v, err := InitializeValue[T](c, key)
+	if err != nil {
 		panic(err)
 	}
 
@@ -169,10 +434,17 @@ func MustWithOptions[T any](c Container, key string, option ...Option) Value[T]
 }
 
 func Set[T any](c Container, v Value[T]) error {
-	err := c.set(v.Key(), v.pointer())
+	err := c.set(v.Key(), v.pointer(), typeOf[T]())
 	if err != nil {
 		return formatErr(fmt.Sprintf("cannot set Value %q to container %q", c.Name(), v.Key()), err.Error())
 	}
 
+	c.notify(ValueSetEvent, v.Key(), typeOf[T](), nil)
+
 	return nil
 }
+
+// typeOf returns the reflect.Type of T, including when T is an interface type.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
@@ -211,4 +211,33 @@ var _ = Describe("Container", func() {
 			})
 		})
 	})
+
+	Describe("checking the Values of a container", func() {
+		It("should check the registered type without calling the provider", func() {
+			c := di.New("check")
+			called := false
+
+			Expect(di.Provide[int](c, "port", func(c di.Container) (int, error) {
+				called = true
+
+				return 8080, nil
+			})).To(Succeed())
+
+			Expect(di.Check[int](c, "port")).To(Succeed())
+			Expect(called).To(BeFalse())
+		})
+
+		It("should report the missing keys", func() {
+			Expect(di.Check[int](di.New("check"), "port")).
+				To(MatchError(ContainSubstring(`cannot get item with key "port" in container "check"`)))
+		})
+
+		It("should report the Values of another type", func() {
+			c := di.New("check")
+			di.MustWithOptions[string](c, "port", di.InitializeOption)
+
+			Expect(di.Check[int](c, "port")).
+				To(MatchError(ContainSubstring(`Value "port" in container "check" is a Value[string], not a Value[int]`)))
+		})
+	})
 })
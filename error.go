@@ -27,6 +27,10 @@ const (
 	ErrConvertingPointer  = "error while converting pointer"
 	ErrNilPointer         = "received nil pointer"
 	ErrAssertingType      = "error while asserting type"
+	ErrInjecting          = "error while injecting Values"
+	ErrResolvingValue     = "error while resolving Value"
+	ErrInstallingModule   = "error while installing Module"
+	ErrRunningHook        = "error while running lifecycle hook"
 )
 
 func ErrGetItemWithKey(key, containerName string, more ...string) error {
@@ -0,0 +1,114 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Graph is the runtime dependency graph of a Container.
+	//
+	// Nodes are the Values held by the Container. An Edge goes from a Value to each Value that was accessed during
+	// its construction by a provider (see Provide). Values that were not constructed yet have no outgoing Edges.
+	Graph struct {
+		Container string      `json:"container"`
+		Nodes     []GraphNode `json:"nodes"`
+		Edges     []GraphEdge `json:"edges"`
+	}
+
+	GraphNode struct {
+		Key  string `json:"key"`
+		Type string `json:"type"`
+	}
+
+	// GraphEdge indicates that To was resolved during the construction of From.
+	GraphEdge struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+)
+
+// NewGraph returns the dependency graph of the Container. Nodes and Edges are sorted by key.
+func NewGraph(c Container) Graph {
+	g := Graph{
+		Container: c.Name(),
+		Nodes:     make([]GraphNode, 0),
+		Edges:     make([]GraphEdge, 0),
+	}
+
+	for _, key := range c.keys() {
+		typ, _ := c.typeOf(key)
+		g.Nodes = append(g.Nodes, GraphNode{Key: key, Type: fmt.Sprint(typ)})
+
+		for _, dependency := range c.dependencies(key) {
+			g.Edges = append(g.Edges, GraphEdge{From: key, To: dependency})
+		}
+	}
+
+	return g
+}
+
+// DOT renders the Graph in the Graphviz DOT language.
+func (g Graph) DOT() string {
+	b := new(strings.Builder)
+
+	fmt.Fprintf(b, "digraph %s {\n", strconv.Quote(g.Container))
+
+	for _, node := range g.Nodes {
+		fmt.Fprintf(b, "\t%s [label=%s];\n", strconv.Quote(node.Key), strconv.Quote(node.Key+"\n"+node.Type))
+	}
+
+	for _, edge := range g.Edges {
+		fmt.Fprintf(b, "\t%s -> %s;\n", strconv.Quote(edge.From), strconv.Quote(edge.To))
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Mermaid renders the Graph as a Mermaid flowchart.
+func (g Graph) Mermaid() string {
+	b := new(strings.Builder)
+	ids := make(map[string]string, len(g.Nodes))
+
+	b.WriteString("flowchart TD\n")
+
+	for i, node := range g.Nodes {
+		ids[node.Key] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(b, "\t%s[\"%s<br/>%s\"]\n", ids[node.Key], mermaidEscape(node.Key), mermaidEscape(node.Type))
+	}
+
+	for _, edge := range g.Edges {
+		fmt.Fprintf(b, "\t%s --> %s\n", ids[edge.From], ids[edge.To])
+	}
+
+	return b.String()
+}
+
+// JSON renders the Graph as indented JSON.
+func (g Graph) JSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ") //nolint:wrapcheck
+}
+
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
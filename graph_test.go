@@ -0,0 +1,100 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di_test
+
+import (
+	"encoding/json"
+
+	"github.com/alexandremahdhaoui/di" //nolint:depguard
+	. "github.com/onsi/ginkgo/v2"      //nolint:depguard
+	. "github.com/onsi/gomega"         //nolint:depguard
+)
+
+var _ = Describe("Graph", func() {
+	var c di.Container
+
+	BeforeEach(func() {
+		c = di.New("graph")
+
+		di.MustWithOptions[string](c, "dsn", di.InitializeOption).MustSet("postgres://")
+		Expect(di.Provide[int](c, "db", func(c di.Container) (int, error) {
+			_ = di.Must[string](c, "dsn")
+
+			return 1, nil
+		})).To(Succeed())
+		Expect(di.Provide[bool](c, "app", func(c di.Container) (bool, error) {
+			_ = di.Must[int](c, "db")
+			_ = di.Must[string](c, "dsn")
+
+			return true, nil
+		})).To(Succeed())
+	})
+
+	It("should not have edges before values are constructed", func() {
+		g := di.NewGraph(c)
+
+		Expect(g.Nodes).To(HaveLen(3))
+		Expect(g.Edges).To(BeEmpty())
+	})
+
+	Context("with a built container", func() {
+		BeforeEach(func() {
+			c.Build()
+		})
+
+		It("should return sorted nodes and edges", func() {
+			g := di.NewGraph(c)
+
+			Expect(g.Container).To(Equal("graph"))
+			Expect(g.Nodes).To(Equal([]di.GraphNode{
+				{Key: "app", Type: "bool"},
+				{Key: "db", Type: "int"},
+				{Key: "dsn", Type: "string"},
+			}))
+			Expect(g.Edges).To(Equal([]di.GraphEdge{
+				{From: "app", To: "db"},
+				{From: "app", To: "dsn"},
+				{From: "db", To: "dsn"},
+			}))
+		})
+
+		It("should render DOT", func() {
+			dot := di.NewGraph(c).DOT()
+
+			Expect(dot).To(HavePrefix(`digraph "graph" {`))
+			Expect(dot).To(ContainSubstring(`"db" [label="db\nint"];`))
+			Expect(dot).To(ContainSubstring(`"app" -> "db";`))
+		})
+
+		It("should render Mermaid", func() {
+			mermaid := di.NewGraph(c).Mermaid()
+
+			Expect(mermaid).To(HavePrefix("flowchart TD\n"))
+			Expect(mermaid).To(ContainSubstring(`n1["db<br/>int"]`))
+			Expect(mermaid).To(ContainSubstring("n0 --> n1"))
+		})
+
+		It("should render JSON", func() {
+			b, err := di.NewGraph(c).JSON()
+			Expect(err).NotTo(HaveOccurred())
+
+			g := di.Graph{}
+			Expect(json.Unmarshal(b, &g)).To(Succeed())
+			Expect(g).To(Equal(di.NewGraph(c)))
+		})
+	})
+})
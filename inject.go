@@ -0,0 +1,156 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+const (
+	// InjectTagName is the struct tag used by Inject to select the fields to populate.
+	InjectTagName = "di"
+
+	injectTagIgnore   = "-"
+	injectTagOptional = "optional"
+)
+
+// Inject populates the fields of the struct pointed to by target with Values held by the Container.
+//
+// Fields are selected with the `di` struct tag:
+//
+//   - `di:"key"` injects the Value identified by key.
+//
+//   - `di:""` injects the only Value whose type can be assigned to the field.
+//
+//   - `di:"key,optional"` or `di:",optional"` leaves the field untouched when no Value can be found.
+//
+//   - `di:"-"` and fields without a `di` tag are ignored.
+func Inject(c Container, target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return formatErr(ErrInjecting, fmt.Sprintf("expected a non-nil pointer to a struct, got %T", target))
+	}
+
+	structValue := rv.Elem()
+	structType := structValue.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		tag, ok := field.Tag.Lookup(InjectTagName)
+		if !ok || tag == injectTagIgnore {
+			continue
+		}
+
+		if !field.IsExported() {
+			return formatErr(ErrInjecting, fmt.Sprintf("cannot inject unexported field %s.%s", structType, field.Name))
+		}
+
+		key, optional := parseInjectTag(tag)
+
+		if key == "" {
+			var err error
+			if key, err = keyByType(c, field.Type); err != nil {
+				if optional {
+					continue
+				}
+
+				return formatErr(ErrInjecting, fmt.Sprintf("field %s.%s", structType, field.Name), err.Error())
+			}
+		}
+
+		typ, ok := c.typeOf(key)
+		if !ok {
+			if optional {
+				continue
+			}
+
+			return ErrGetItemWithKey(key, c.Name(), ErrInjecting, fmt.Sprintf("field %s.%s", structType, field.Name))
+		}
+
+		if !typ.AssignableTo(field.Type) {
+			return formatErr(ErrInjecting, fmt.Sprintf("field %s.%s", structType, field.Name),
+				fmt.Sprintf("Value %q of type %v is not assignable to %v", key, typ, field.Type))
+		}
+
+		ptr, err := c.get(key)
+		if err != nil {
+			return formatErr(ErrInjecting, fmt.Sprintf("field %s.%s", structType, field.Name), err.Error())
+		}
+
+		structValue.Field(i).Set(reflect.NewAt(typ, unsafe.Pointer(ptr)).Elem())
+	}
+
+	return nil
+}
+
+// MustInject calls Inject and panics if an error occurs.
+func MustInject(c Container, target any) {
+	if err := Inject(c, target); err != nil {
+		panic(err)
+	}
+}
+
+func parseInjectTag(tag string) (string, bool) {
+	key, opts, _ := strings.Cut(tag, ",")
+
+	for _, opt := range strings.Split(opts, ",") {
+		if strings.TrimSpace(opt) == injectTagOptional {
+			return key, true
+		}
+	}
+
+	return key, false
+}
+
+// keyByType returns the key of the only Value whose type is typ. If no Value has this exact type, it falls back to
+// the only Value whose type is assignable to typ.
+func keyByType(c Container, typ reflect.Type) (string, error) {
+	identical := make([]string, 0)
+	assignable := make([]string, 0)
+
+	for _, key := range c.keys() {
+		t, ok := c.typeOf(key)
+		if !ok {
+			continue
+		}
+
+		if t == typ {
+			identical = append(identical, key)
+		} else if t.AssignableTo(typ) {
+			assignable = append(assignable, key)
+		}
+	}
+
+	candidates := identical
+	if len(candidates) == 0 {
+		candidates = assignable
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", formatErr(fmt.Sprintf("no Value of type %v in container %q", typ, c.Name()))
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", formatErr(fmt.Sprintf("ambiguous Values of type %v in container %q: %s",
+			typ, c.Name(), strings.Join(candidates, ", ")))
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di_test
+
+import (
+	"github.com/alexandremahdhaoui/di" //nolint:depguard
+	. "github.com/onsi/ginkgo/v2"      //nolint:depguard
+	. "github.com/onsi/gomega"         //nolint:depguard
+)
+
+type (
+	injectByKey struct {
+		Name    string `di:"name"`
+		Port    int    `di:"port"`
+		Ignored string `di:"-"`
+		NoTag   string
+	}
+
+	injectByType struct {
+		Iface testIface `di:""`
+		Flags []bool    `di:""`
+	}
+
+	injectOptional struct {
+		Missing string `di:"missing,optional"`
+		ByType  int64  `di:",optional"`
+	}
+
+	injectMissing struct {
+		Missing string `di:"missing"`
+	}
+
+	injectUnexported struct {
+		name string `di:"name"`
+	}
+
+	injectWrongType struct {
+		Name int `di:"name"`
+	}
+)
+
+var _ = Describe("Inject", func() {
+	var c di.Container
+
+	BeforeEach(func() {
+		c = di.New("inject")
+
+		di.MustWithOptions[string](c, "name", di.InitializeOption).MustSet("di")
+		di.MustWithOptions[int](c, "port", di.InitializeOption).MustSet(8080)
+		di.MustWithOptions[testIface](c, "iface", di.InitializeOption).MustSet(&testConcreteIface{b: "iface"})
+		di.MustWithOptions[[]bool](c, "flags", di.InitializeOption).MustSet([]bool{true})
+	})
+
+	Context("with keys", func() {
+		It("should populate tagged fields", func() {
+			target := injectByKey{Ignored: "ignored", NoTag: "no-tag"}
+			Expect(di.Inject(c, &target)).To(Succeed())
+
+			Expect(target.Name).To(Equal("di"))
+			Expect(target.Port).To(Equal(8080))
+			Expect(target.Ignored).To(Equal("ignored"))
+			Expect(target.NoTag).To(Equal("no-tag"))
+		})
+	})
+
+	Context("with types", func() {
+		It("should populate fields with the only Value of the field's type", func() {
+			target := injectByType{}
+			Expect(di.Inject(c, &target)).To(Succeed())
+
+			Expect(target.Iface.test()).To(Equal("iface"))
+			Expect(target.Flags).To(Equal([]bool{true}))
+		})
+
+		It("should fail on ambiguous types", func() {
+			di.MustWithOptions[[]bool](c, "other-flags", di.InitializeOption).MustSet([]bool{false})
+
+			Expect(di.Inject(c, &injectByType{})).To(MatchError(ContainSubstring("ambiguous")))
+		})
+	})
+
+	Context("with optional fields", func() {
+		It("should leave missing fields untouched", func() {
+			target := injectOptional{Missing: "default", ByType: 1}
+			Expect(di.Inject(c, &target)).To(Succeed())
+
+			Expect(target.Missing).To(Equal("default"))
+			Expect(target.ByType).To(Equal(int64(1)))
+		})
+	})
+
+	Context("with a built container", func() {
+		It("should populate tagged fields", func() {
+			c.Build()
+
+			target := injectByKey{}
+			Expect(di.Inject(c, &target)).To(Succeed())
+			Expect(target.Name).To(Equal("di"))
+		})
+	})
+
+	It("should fail on missing keys", func() {
+		Expect(di.Inject(c, &injectMissing{})).To(MatchError(ContainSubstring(`"missing"`)))
+	})
+
+	It("should fail on unexported fields", func() {
+		Expect(di.Inject(c, &injectUnexported{})).To(MatchError(ContainSubstring("unexported field")))
+	})
+
+	It("should fail on mismatching types", func() {
+		Expect(di.Inject(c, &injectWrongType{})).To(MatchError(ContainSubstring("not assignable")))
+	})
+
+	It("should fail if target is not a pointer to a struct", func() {
+		Expect(di.Inject(c, injectByKey{})).NotTo(Succeed())
+		Expect(di.Inject(c, (*injectByKey)(nil))).NotTo(Succeed())
+	})
+
+	It("should panic with MustInject", func() {
+		Expect(func() { di.MustInject(c, &injectMissing{}) }).To(Panic())
+	})
+})
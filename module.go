@@ -0,0 +1,230 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type (
+	// Module is a named bundle of Providers and lifecycle hooks that can be installed into a Container.
+	// A Module may require other Modules, which are installed before it.
+	//
+	// Example:
+	//
+	//	var Postgres = di.NewModule("postgres").
+	//		Requires(Observability).
+	//		Provides(di.NewProvider[*sql.DB]("db", newDB)).
+	//		OnStop(closeDB)
+	Module interface {
+		Name() string
+
+		// Requires adds Modules that must be installed before this Module.
+		Requires(modules ...Module) Module
+		// Provides adds Providers that are called when the Module is installed.
+		Provides(providers ...Provider) Module
+		// OnStart adds hooks that are called by Start.
+		OnStart(hooks ...Hook) Module
+		// OnStop adds hooks that are called by Stop.
+		OnStop(hooks ...Hook) Module
+
+		requirements() []Module
+		providers() []Provider
+		startHooks() []Hook
+		stopHooks() []Hook
+	}
+
+	// Hook is a lifecycle function of a Module.
+	Hook func(ctx context.Context, c Container) error
+
+	module struct {
+		name     string
+		requires []Module
+		provides []Provider
+		onStart  []Hook
+		onStop   []Hook
+	}
+)
+
+func (m *module) Name() string {
+	return m.name
+}
+
+func (m *module) Requires(modules ...Module) Module { //nolint:ireturn
+	m.requires = append(m.requires, modules...)
+
+	return m
+}
+
+func (m *module) Provides(providers ...Provider) Module { //nolint:ireturn
+	m.provides = append(m.provides, providers...)
+
+	return m
+}
+
+func (m *module) OnStart(hooks ...Hook) Module { //nolint:ireturn
+	m.onStart = append(m.onStart, hooks...)
+
+	return m
+}
+
+func (m *module) OnStop(hooks ...Hook) Module { //nolint:ireturn
+	m.onStop = append(m.onStop, hooks...)
+
+	return m
+}
+
+func (m *module) requirements() []Module {
+	return m.requires
+}
+
+func (m *module) providers() []Provider {
+	return m.provides
+}
+
+func (m *module) startHooks() []Hook {
+	return m.onStart
+}
+
+func (m *module) stopHooks() []Hook {
+	return m.onStop
+}
+
+func NewModule(name string) Module { //nolint:ireturn
+	if name == "" {
+		panic("a name is required to create a new module")
+	}
+
+	return &module{name: name}
+}
+
+// Install installs the given Modules and their requirements into the Container, in dependency order.
+//
+// Modules are identified by their name: a Module that is already installed in the Container is skipped, and a different
+// Module with the same name is rejected. Install is atomic: if a Module cannot be installed, the Values provided and the
+// Modules installed by this call are removed from the Container.
+func Install(c Container, modules ...Module) error {
+	installed := make(map[string]Module)
+	for _, m := range c.modules() {
+		installed[m.Name()] = m
+	}
+
+	visiting := make(map[string]bool)
+
+	var visit func(m Module, path []string) error
+
+	visit = func(m Module, path []string) error {
+		path = append(path, m.Name())
+
+		if other, ok := installed[m.Name()]; ok {
+			if other != m {
+				return formatErr(ErrInstallingModule,
+					fmt.Sprintf("another module named %q is already installed in container %q", m.Name(), c.Name()))
+			}
+
+			return nil
+		}
+
+		if visiting[m.Name()] {
+			return formatErr(ErrInstallingModule, fmt.Sprintf("circular dependency between modules: %v", path))
+		}
+
+		visiting[m.Name()] = true
+		defer delete(visiting, m.Name())
+
+		for _, requirement := range m.requirements() {
+			if err := visit(requirement, path); err != nil {
+				return err
+			}
+		}
+
+		for _, provider := range m.providers() {
+			if err := provider(c); err != nil {
+				return formatErr(ErrInstallingModule,
+					fmt.Sprintf("cannot install module %q in container %q", m.Name(), c.Name()), err.Error())
+			}
+		}
+
+		installed[m.Name()] = m
+		c.install(m)
+
+		return nil
+	}
+
+	rollback := c.checkpoint()
+
+	for _, m := range modules {
+		if err := visit(m, nil); err != nil {
+			rollback()
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MustInstall calls Install and panics if an error occurs.
+func MustInstall(c Container, modules ...Module) {
+	if err := Install(c, modules...); err != nil {
+		panic(err)
+	}
+}
+
+// Start calls the OnStart hooks of the Modules installed in the Container, in installation order.
+// Start returns on the first failing hook, after calling the OnStop hooks of the Modules already started, in reverse
+// installation order. The errors of the OnStop hooks are joined to the error of the failing hook.
+func Start(ctx context.Context, c Container) error {
+	installed := c.modules()
+
+	for i, m := range installed {
+		for _, hook := range m.startHooks() {
+			if err := hook(ctx, c); err != nil {
+				err = formatErr(ErrRunningHook, fmt.Sprintf("cannot start module %q", m.Name()), err.Error())
+
+				return errors.Join(append([]error{err}, stop(ctx, c, installed[:i])...)...)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Stop calls the OnStop hooks of the Modules installed in the Container, in reverse installation order.
+// All hooks are called, and their errors are joined.
+func Stop(ctx context.Context, c Container) error {
+	return errors.Join(stop(ctx, c, c.modules())...)
+}
+
+// stop calls the OnStop hooks of modules in reverse order, and returns their errors.
+func stop(ctx context.Context, c Container, modules []Module) []error {
+	errs := make([]error, 0)
+
+	for i := len(modules) - 1; i >= 0; i-- {
+		m := modules[i]
+
+		for _, hook := range m.stopHooks() {
+			if err := hook(ctx, c); err != nil {
+				errs = append(errs, formatErr(ErrRunningHook, fmt.Sprintf("cannot stop module %q", m.Name()), err.Error()))
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alexandremahdhaoui/di" //nolint:depguard
+	. "github.com/onsi/ginkgo/v2"      //nolint:depguard
+	. "github.com/onsi/gomega"         //nolint:depguard
+)
+
+var _ = Describe("Module", func() {
+	var c di.Container
+	var events []string
+
+	hook := func(event string) di.Hook {
+		return func(ctx context.Context, c di.Container) error {
+			events = append(events, event)
+
+			return nil
+		}
+	}
+
+	var observability, postgres, app di.Module
+
+	BeforeEach(func() {
+		c = di.New("module")
+		events = nil
+
+		observability = di.NewModule("observability").
+			Provides(di.NewValueProvider[string]("log-level", "info")).
+			OnStart(hook("start observability")).
+			OnStop(hook("stop observability"))
+
+		postgres = di.NewModule("postgres").
+			Requires(observability).
+			Provides(di.NewProvider[string]("dsn", func(c di.Container) (string, error) {
+				return "postgres://" + di.Must[string](c, "log-level").MustValue(), nil
+			})).
+			OnStart(hook("start postgres")).
+			OnStop(hook("stop postgres"))
+
+		app = di.NewModule("app").Requires(postgres, observability)
+	})
+
+	It("should panic without a name", func() {
+		Expect(func() { di.NewModule("") }).To(Panic())
+	})
+
+	It("should install modules and their requirements", func() {
+		Expect(di.Install(c, app)).To(Succeed())
+
+		Expect(di.Must[string](c, "dsn").MustValue()).To(Equal("postgres://info"))
+	})
+
+	It("should install a module only once", func() {
+		Expect(di.Install(c, observability)).To(Succeed())
+		Expect(di.Install(c, app, postgres)).To(Succeed())
+
+		Expect(di.Start(context.Background(), c)).To(Succeed())
+		Expect(events).To(Equal([]string{"start observability", "start postgres"}))
+	})
+
+	It("should remove the Values and the Modules of a failed install", func() {
+		level := "debug"
+		Expect(di.Set[string](c, di.NewValue[string]("log-level", &level))).To(Succeed())
+
+		failing := di.NewModule("failing").
+			Requires(postgres).
+			Provides(func(c di.Container) error { return errors.New("boom") })
+		Expect(di.Install(c, failing)).To(MatchError(ContainSubstring("boom")))
+
+		Expect(di.Must[string](c, "log-level").MustValue()).To(Equal("debug"))
+		_, err := di.Get[string](c, "dsn")
+		Expect(err).To(HaveOccurred())
+
+		By("installing the modules of the failed install again")
+		Expect(di.Install(c, app)).To(Succeed())
+		Expect(di.Start(context.Background(), c)).To(Succeed())
+		Expect(events).To(Equal([]string{"start observability", "start postgres"}))
+	})
+
+	It("should reject a different module with the same name", func() {
+		Expect(di.Install(c, observability)).To(Succeed())
+
+		Expect(di.Install(c, app, di.NewModule("app"))).
+			To(MatchError(ContainSubstring(`another module named "app" is already installed`)))
+		Expect(di.Install(c, di.NewModule("observability"))).
+			To(MatchError(ContainSubstring(`another module named "observability" is already installed`)))
+
+		Expect(di.Start(context.Background(), c)).To(Succeed())
+		Expect(events).To(Equal([]string{"start observability"}))
+	})
+
+	It("should run lifecycle hooks in dependency order", func() {
+		Expect(di.Install(c, app)).To(Succeed())
+
+		Expect(di.Start(context.Background(), c)).To(Succeed())
+		Expect(di.Stop(context.Background(), c)).To(Succeed())
+		Expect(events).To(Equal([]string{
+			"start observability",
+			"start postgres",
+			"stop postgres",
+			"stop observability",
+		}))
+	})
+
+	It("should run every stop hook and join their errors", func() {
+		postgres.OnStop(func(ctx context.Context, c di.Container) error { return errors.New("boom") })
+		Expect(di.Install(c, app)).To(Succeed())
+
+		Expect(di.Stop(context.Background(), c)).To(MatchError(ContainSubstring("boom")))
+		Expect(events).To(ContainElement("stop observability"))
+	})
+
+	It("should stop the started modules when a start hook fails", func() {
+		cache := di.NewModule("cache").
+			Requires(postgres).
+			OnStart(hook("start cache")).
+			OnStop(hook("stop cache"))
+		cache.OnStart(func(ctx context.Context, c di.Container) error { return errors.New("boom") })
+		postgres.OnStop(func(ctx context.Context, c di.Container) error { return errors.New("bang") })
+		Expect(di.Install(c, app, cache)).To(Succeed())
+
+		err := di.Start(context.Background(), c)
+		Expect(err).To(MatchError(ContainSubstring("boom")))
+		Expect(err).To(MatchError(ContainSubstring("bang")))
+		Expect(events).To(Equal([]string{
+			"start observability",
+			"start postgres",
+			"start cache",
+			"stop postgres",
+			"stop observability",
+		}))
+	})
+
+	It("should detect circular dependencies", func() {
+		a := di.NewModule("a")
+		b := di.NewModule("b").Requires(a)
+		a.Requires(b)
+
+		Expect(di.Install(c, a)).To(MatchError(ContainSubstring("circular dependency")))
+	})
+
+	It("should fail installing into a built container", func() {
+		c.Build()
+
+		Expect(di.Install(c, app)).NotTo(Succeed())
+		Expect(func() { di.MustInstall(c, app) }).To(Panic())
+	})
+})
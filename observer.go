@@ -0,0 +1,126 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"time"
+)
+
+const (
+	// ValueSetEvent is emitted when a Value is set with Set.
+	ValueSetEvent EventKind = iota
+	// ValueInitializedEvent is emitted when a Value is initialized with InitializeValue or the InitializeOption.
+	ValueInitializedEvent
+	// ValueProvidedEvent is emitted when the provider of a Value is registered with Provide.
+	ValueProvidedEvent
+	// ValueResolvedEvent is emitted when a provider successfully constructed its Value.
+	ValueResolvedEvent
+	// ValueOverriddenEvent is emitted when a Value replaces another Value with the same key.
+	ValueOverriddenEvent
+	// BuildStartedEvent is emitted when the Container starts building.
+	BuildStartedEvent
+	// BuildFinishedEvent is emitted when the Container is built.
+	BuildFinishedEvent
+	// ErrorEvent is emitted when an operation on the Container fails.
+	ErrorEvent
+)
+
+type (
+	EventKind int
+
+	// Event describes something that happened in a Container.
+	Event struct {
+		Kind EventKind
+		// Container is the name of the Container emitting the Event.
+		Container string
+		// Key (optional) identifies the Value concerned by the Event.
+		Key string
+		// Type (optional) is the type T of the Value[T] concerned by the Event.
+		Type reflect.Type
+		// Err is set for ErrorEvent.
+		Err  error
+		Time time.Time
+	}
+
+	// Observer receives the Events of the Containers it is added to. See Container.AddObserver.
+	Observer interface {
+		OnEvent(e Event)
+	}
+
+	// ObserverFunc is an adapter to use ordinary functions as Observer.
+	ObserverFunc func(e Event)
+
+	slogObserver struct {
+		logger *slog.Logger
+	}
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case ValueSetEvent:
+		return "value set"
+	case ValueInitializedEvent:
+		return "value initialized"
+	case ValueProvidedEvent:
+		return "value provided"
+	case ValueResolvedEvent:
+		return "value resolved"
+	case ValueOverriddenEvent:
+		return "value overridden"
+	case BuildStartedEvent:
+		return "build started"
+	case BuildFinishedEvent:
+		return "build finished"
+	case ErrorEvent:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+func (f ObserverFunc) OnEvent(e Event) {
+	f(e)
+}
+
+// NewSlogObserver returns an Observer logging Events with log/slog. ErrorEvent are logged at the error level, and other
+// Events at the debug level.
+func NewSlogObserver(logger *slog.Logger) Observer { //nolint:ireturn
+	return &slogObserver{logger: logger}
+}
+
+func (o *slogObserver) OnEvent(e Event) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{slog.String("container", e.Container)}
+
+	if e.Key != "" {
+		attrs = append(attrs, slog.String("key", e.Key))
+	}
+
+	if e.Type != nil {
+		attrs = append(attrs, slog.String("type", e.Type.String()))
+	}
+
+	if e.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+
+	o.logger.LogAttrs(context.Background(), level, e.Kind.String(), attrs...)
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di_test
+
+import (
+	"bytes"
+	"log/slog"
+
+	"github.com/alexandremahdhaoui/di" //nolint:depguard
+	. "github.com/onsi/ginkgo/v2"      //nolint:depguard
+	. "github.com/onsi/gomega"         //nolint:depguard
+)
+
+var _ = Describe("Observer", func() {
+	var c di.Container
+	var events []di.Event
+
+	kinds := func() []di.EventKind {
+		sl := make([]di.EventKind, 0, len(events))
+		for _, e := range events {
+			sl = append(sl, e.Kind)
+		}
+
+		return sl
+	}
+
+	BeforeEach(func() {
+		c = di.New("observer")
+		events = nil
+
+		c.AddObserver(di.ObserverFunc(func(e di.Event) {
+			events = append(events, e)
+		}))
+	})
+
+	It("should receive value events", func() {
+		di.MustWithOptions[int](c, "port", di.InitializeOption)
+		Expect(di.Set(c, di.NewValue("port", new(int)))).To(Succeed())
+		Expect(di.Provide[string](c, "addr", func(c di.Container) (string, error) { return "localhost", nil })).
+			To(Succeed())
+		_ = di.Must[string](c, "addr")
+
+		Expect(kinds()).To(Equal([]di.EventKind{
+			di.ValueInitializedEvent,
+			di.ValueOverriddenEvent,
+			di.ValueSetEvent,
+			di.ValueProvidedEvent,
+			di.ValueResolvedEvent,
+		}))
+		Expect(events[0].Container).To(Equal("observer"))
+		Expect(events[0].Key).To(Equal("port"))
+		Expect(events[0].Type.String()).To(Equal("int"))
+		Expect(events[0].Time).NotTo(BeZero())
+	})
+
+	It("should receive build events", func() {
+		Expect(di.Provide[string](c, "addr", func(c di.Container) (string, error) { return "localhost", nil })).
+			To(Succeed())
+		events = nil
+
+		c.Build()
+
+		Expect(kinds()).To(Equal([]di.EventKind{
+			di.BuildStartedEvent,
+			di.ValueResolvedEvent,
+			di.BuildFinishedEvent,
+		}))
+	})
+
+	It("should receive error events", func() {
+		_, err := di.Get[int](c, "missing")
+		Expect(err).To(HaveOccurred())
+
+		c.Build()
+		Expect(di.Set(c, di.NewValue("late", new(int)))).NotTo(Succeed())
+
+		Expect(kinds()).To(Equal([]di.EventKind{
+			di.ErrorEvent,
+			di.BuildStartedEvent,
+			di.BuildFinishedEvent,
+			di.ErrorEvent,
+		}))
+		Expect(events[0].Key).To(Equal("missing"))
+		Expect(events[0].Err).To(MatchError(err))
+		Expect(events[3].Key).To(Equal("late"))
+	})
+
+	It("should log events with slog", func() {
+		buffer := new(bytes.Buffer)
+		c.AddObserver(di.NewSlogObserver(slog.New(slog.NewTextHandler(buffer, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+		}))))
+
+		di.MustWithOptions[int](c, "port", di.InitializeOption)
+		_, _ = di.Get[int](c, "missing")
+
+		Expect(buffer.String()).To(ContainSubstring(`level=DEBUG msg="value initialized" container=observer key=port type=int`))
+		Expect(buffer.String()).To(ContainSubstring(`level=ERROR msg=error container=observer key=missing error=`))
+	})
+})
@@ -135,16 +135,20 @@ func ContainerDeclFromNode(node ast.Node, meta Meta, diPkgIdent Ident) []Decl {
 	return dropDuplicate(sl)
 }
 
-// dropDuplicates takes a slice of Decl, remove any duplicate Ident & return a new slice
+// dropDuplicates takes a slice of Decl, remove any duplicate Ident & return a new slice.
+// The last Decl of an Ident is kept, and Decls keep the order in which their Ident first appears.
 func dropDuplicate(sl []Decl) []Decl {
 	cleaned := make([]Decl, 0)
 
-	m := make(map[Ident]Decl)
+	indexes := make(map[Ident]int)
 	for _, decl := range sl {
-		m[decl.Ident] = decl
-	}
+		if i, ok := indexes[decl.Ident]; ok {
+			cleaned[i] = decl
+
+			continue
+		}
 
-	for _, decl := range m {
+		indexes[decl.Ident] = len(cleaned)
 		cleaned = append(cleaned, decl)
 	}
 
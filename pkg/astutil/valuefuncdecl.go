@@ -30,6 +30,11 @@ type ValuefuncDecl struct {
 }
 
 func findContainerRef(fn *ast.FuncDecl, diPkgIdent Ident) (ObjRef, bool) {
+	// funcs implemented outside Go, e.g. in assembly, have no body.
+	if fn.Body == nil {
+		return ObjRef{}, false
+	}
+
 	for _, v := range fn.Body.List {
 		switch stmt := v.(type) {
 		case *ast.ReturnStmt:
@@ -75,6 +80,10 @@ func findContainerRef(fn *ast.FuncDecl, diPkgIdent Ident) (ObjRef, bool) {
 						continue
 					}
 
+					if len(expr.Args) == 0 {
+						continue
+					}
+
 					// ContainerRef is the first arg in the func call
 					return exprToObjRef(expr.Args[0])
 				default:
@@ -91,6 +100,11 @@ func findContainerRef(fn *ast.FuncDecl, diPkgIdent Ident) (ObjRef, bool) {
 func findReturnTypes(fn *ast.FuncDecl) []ObjRef {
 	sl := make([]ObjRef, 0)
 
+	// funcs without results have no result list.
+	if fn.Type.Results == nil {
+		return sl
+	}
+
 	for _, res := range fn.Type.Results.List {
 		index, ok := res.Type.(*ast.IndexExpr)
 		if !ok {
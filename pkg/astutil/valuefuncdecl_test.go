@@ -0,0 +1,58 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package astutil_test
+
+import (
+	"github.com/alexandremahdhaoui/di/pkg/astutil"
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+	"go/parser"
+	"go/token"
+)
+
+var _ = Describe("ValuefuncDeclFromNode", func() {
+	DescribeTable("should find the ValueFuncs of a file",
+		func(src string, expected ...string) {
+			file, err := parser.ParseFile(token.NewFileSet(), "file.go", src, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			idents := make([]string, 0)
+			for _, decl := range astutil.ValuefuncDeclFromNode(file, astutil.Meta{}, "di") { //nolint:exhaustruct
+				idents = append(idents, decl.Decl.Ident.String())
+			}
+
+			Expect(idents).To(ConsistOf(expected))
+		},
+		Entry("a ValueFunc", `package p
+import "github.com/alexandremahdhaoui/di"
+func Port(options ...di.Option) di.Value[int] {
+	return di.MustWithOptions[int](di.DefaultContainer, "Port", options...)
+}`, "Port"),
+		Entry("a func without results", `package p
+import "github.com/alexandremahdhaoui/di"
+func Use() {}
+var _ = di.New`),
+		Entry("a func without body", `package p
+import "github.com/alexandremahdhaoui/di"
+func Port(options ...di.Option) di.Value[int]`),
+		Entry("a ValueFunc calling di without arguments", `package p
+import "github.com/alexandremahdhaoui/di"
+func Port() di.Value[int] {
+	return di.Must[int]()
+}`),
+	)
+})
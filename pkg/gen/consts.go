@@ -17,7 +17,14 @@ limitations under the License.
 package gen
 
 const (
-	DIMarkerName        = "di"
-	ContainerMarkerName = "container"
-	ValueFuncMarkerName = "valuefunc"
+	DIMarkerName         = "di"
+	ContainerMarkerName  = "container"
+	ValueFuncMarkerName  = "valuefunc"
+	ProvideMarkerName    = "provide"
+	InjectorMarkerName   = "injector"
+	InjectMarkerName     = "inject"
+	FakeMarkerName       = "fake"
+	ManifestMarkerName   = "manifest"
+	DocsMarkerName       = "docs"
+	EntrypointMarkerName = "entrypoint"
 )
@@ -22,6 +22,7 @@ import (
 	"github.com/alexandremahdhaoui/di/pkg/diutil"
 	"github.com/dave/jennifer/jen"
 	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
 
@@ -33,6 +34,9 @@ type Container struct {
 	// Exported indicates if the Container should be exported or not.
 	// The Container is not exported by default.
 	Exported *bool
+	// Typed indicates if a typed container struct should be generated.
+	// The typed container is not generated by default.
+	Typed *bool
 }
 
 func (c *Container) nameWithExportedCasing() string {
@@ -51,6 +55,23 @@ func (c *Container) isExported() bool {
 	return *c.Exported
 }
 
+func (c *Container) isTyped() bool {
+	if c.Typed == nil {
+		return false
+	}
+
+	return *c.Typed
+}
+
+// typedName returns the name of the typed container struct, e.g. TypedApp or typedApp.
+func (c *Container) typedName() string {
+	if c.isExported() {
+		return "Typed" + title(c.Name)
+	}
+
+	return "typed" + title(c.Name)
+}
+
 var ContainerMarkerDefinition = markers.Must( //nolint:gochecknoglobals
 	markers.MakeDefinition(markerName(DIMarkerName, ContainerMarkerName),
 		markers.DescribesPackage, Container{}), //exhaustruct,exhaustivestruct
@@ -66,16 +87,33 @@ var ContainerMarkerDefinition = markers.Must( //nolint:gochecknoglobals
 //
 //   - Exported (optional bool) indicates if the Container should be exported or not.
 //     The Container is not exported by default.
+//
+//   - Typed (optional bool) indicates if a typed container struct should be generated.
+//     The struct exposes one accessor per ValueFunc bound to the Container, and the Build and Validate methods.
+//     The typed container is not generated by default.
 type ContainerGenerator struct {
 	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	// The header is a text/template, see HeaderData. Without a header file, the header names the generated package.
 	HeaderFile string `marker:",optional"`
 
-	// Year specifies the year to substitute for " YEAR" in the header file.
+	// Year specifies the year to substitute for " YEAR" and {{ .Year }} in the header file.
 	Year string `marker:",optional"`
+
+	// Filename specifies the name of the generated file. The file is named zz_generated.di.container.go by default.
+	Filename string `marker:",optional"`
+
+	// BuildTag specifies a build constraint expression, e.g. "!wasm", that must be satisfied to build the generated
+	// file, in addition to !ignore_autogenerated.
+	BuildTag string `marker:",optional"`
+
+	// SplitByContainer generates one file per Container, suffixed with the name of the Container, e.g.
+	// zz_generated.di.container.App.go. The Containers are generated in a single file by default.
+	SplitByContainer bool `marker:",optional"`
 }
 
 func (ContainerGenerator) RegisterMarkers(into *markers.Registry) error {
-	if err := markers.RegisterAll(into, ContainerMarkerDefinition); err != nil {
+	// ValueFuncMarkerDefinition is registered to generate the accessors of typed containers.
+	if err := markers.RegisterAll(into, ContainerMarkerDefinition, ValueFuncMarkerDefinition); err != nil {
 		return err //nolint:wrapcheck
 	}
 
@@ -93,47 +131,197 @@ func (g ContainerGenerator) Generate(ctx *genall.GenerationContext) error {
 			root.AddError(err)
 		}
 
+		if errs := validateMarkers(ctx.Collector.Registry, root); len(errs) > 0 {
+			addErrors(root, errs...)
+
+			continue
+		}
+
 		markerValues := markerSet[ContainerMarkerDefinition.Name]
 		if len(markerValues) == 0 {
 			continue
 		}
 
-		// We create one zz_generated.di.container.go per package
-		// Thus we also instantiate one jen.File per package.
-		f := jen.NewFilePath(root.PkgPath) //nolint:varnamelen
-		varDefinitions := make([]jen.Code, 0)
+		sortMarkerValues(markerValues, func(value interface{}) string {
+			container := value.(Container) //nolint:forcetypeassert
+
+			return container.nameWithExportedCasing()
+		})
+		sortMarkerValues(markerSet[ValueFuncMarkerDefinition.Name], func(value interface{}) string {
+			valueFunc := value.(ValueFunc) //nolint:forcetypeassert
+
+			return valueFunc.funcName()
+		})
+
+		filename := outputFilename(g.Filename, ContainerMarkerName)
+		files := make(map[string][]interface{})
 
 		for _, markerValue := range markerValues {
-			container := markerValue.(Container) //nolint:forcetypeassert
+			name := filename
+			if g.SplitByContainer {
+				container := markerValue.(Container) //nolint:forcetypeassert
+				name = splitFilename(filename, container.nameWithExportedCasing())
+			}
 
-			varDefinitions = append(varDefinitions, jen.
-				Id(container.nameWithExportedCasing()).
-				Op("=").
-				Qual(diutil.PkgPath, "New").
-				Call(jen.Lit(container.nameWithExportedCasing())))
+			files[name] = append(files[name], markerValue)
 		}
 
-		f.Var().Defs(varDefinitions...)
+		for _, name := range sortedKeys(files) {
+			if err := g.generateFile(ctx, root, name, files[name], markerSet[ValueFuncMarkerDefinition.Name]); err != nil {
+				return err
+			}
+		}
+	}
 
-		buffer := &bytes.Buffer{}
-		if err = f.Render(buffer); err != nil {
-			root.AddError(err)
+	return nil
+}
 
-			return err
+// generateFile generates the Containers of the markerValues of root in the file filename.
+func (g ContainerGenerator) generateFile(ctx *genall.GenerationContext, root *loader.Package, filename string,
+	markerValues, valueFuncMarkers []interface{},
+) error {
+	// We create one jen.File per generated file.
+	f := jen.NewFilePath(root.PkgPath) //nolint:varnamelen
+	varDefinitions := make([]jen.Code, 0)
+
+	for _, markerValue := range markerValues {
+		container := markerValue.(Container) //nolint:forcetypeassert
+
+		varDefinitions = append(varDefinitions, jen.
+			Id(container.nameWithExportedCasing()).
+			Op("=").
+			Qual(diutil.PkgPath, "New").
+			Call(jen.Lit(container.nameWithExportedCasing())))
+	}
+
+	f.Var().Defs(varDefinitions...)
+
+	hasErrors := false
+
+	for _, markerValue := range markerValues {
+		container := markerValue.(Container) //nolint:forcetypeassert
+		if !container.isTyped() {
+			continue
 		}
 
-		if err = generateFile(generateFileOptions{
-			buffer:     buffer,
-			ctx:        ctx,
-			filename:   generatedFilename(DIMarkerName, ContainerMarkerName),
-			headerFile: g.HeaderFile,
-			headerYear: g.Year,
-			root:       root,
-		}); err != nil {
+		if err := typedContainerCode(f, container, valueFuncMarkers, newTypeResolver(root)); err != nil {
 			root.AddError(err)
 
+			hasErrors = true
+		}
+	}
+
+	if hasErrors {
+		return nil
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := f.Render(buffer); err != nil {
+		root.AddError(err)
+
+		return err //nolint:wrapcheck
+	}
+
+	if err := generateFile(generateFileOptions{
+		buffer:     buffer,
+		generator:  ContainerMarkerName,
+		ctx:        ctx,
+		filename:   filename,
+		buildTag:   g.BuildTag,
+		headerFile: g.HeaderFile,
+		year:       g.Year,
+		root:       root,
+	}); err != nil {
+		root.AddError(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// typedContainerCode generates the typed container struct of container:
+//
+//	type TypedApp struct{}
+//
+//	func (TypedApp) Container() di.Container { return App }
+//
+//	func (TypedApp) Build() { App.Build() }
+//
+//	func (TypedApp) Validate() error { ... }
+//
+//	func (TypedApp) Name(options ...di.Option) di.Value[typeimport.Type] {
+//		return di.MustWithOptions[typeimport.Type](App, "Name", options...)
+//	}
+func typedContainerCode(f *jen.File, container Container, valueFuncMarkers []interface{}, r *typeResolver) error {
+	typedName := container.typedName()
+	containerName := container.nameWithExportedCasing()
+	recv := jen.Id(typedName)
+
+	valueFuncs := make([]ValueFunc, 0)
+	typeStts := make([]*jen.Statement, 0)
+
+	for _, markerValue := range valueFuncMarkers {
+		valueFunc := markerValue.(ValueFunc) //nolint:forcetypeassert
+		if !valueFunc.inContainer(r.root.PkgPath, containerName) {
+			continue
+		}
+
+		typeStt, err := valueFunc.typeCode(r)
+		if err != nil {
 			return err
 		}
+
+		valueFuncs = append(valueFuncs, valueFunc)
+		typeStts = append(typeStts, typeStt)
+	}
+
+	f.Commentf("%s is the typed view of the %s container.", typedName, containerName)
+	f.Type().Id(typedName).Struct()
+
+	f.Comment("Container returns the underlying di.Container.")
+	f.Func().Params(recv.Clone()).Id("Container").Params().Qual(diutil.PkgPath, "Container").Block(
+		jen.Return(jen.Id(containerName)),
+	)
+
+	f.Comment("Build builds the underlying di.Container.")
+	f.Func().Params(recv.Clone()).Id("Build").Params().Block(
+		jen.Id(containerName).Dot("Build").Call(),
+	)
+
+	checks := make([]jen.Code, 0, len(valueFuncs)+2) //nolint:gomnd
+	checks = append(checks, jen.Id("errs").Op(":=").Make(jen.Index().Error(), jen.Lit(0)))
+
+	for i, valueFunc := range valueFuncs {
+		checks = append(checks, jen.If(
+			jen.Err().Op(":=").Qual(diutil.PkgPath, "Check").Types(typeStts[i]).
+				Call(jen.Id(containerName), jen.Lit(valueFunc.key())),
+			jen.Err().Op("!=").Nil(),
+		).Block(
+			jen.Id("errs").Op("=").Append(jen.Id("errs"), jen.Err()),
+		))
+	}
+
+	checks = append(checks, jen.Return(jen.Qual("errors", "Join").Call(jen.Id("errs").Op("..."))))
+
+	f.Comment("Validate returns an error if a Value of the container is not registered with its type, without calling")
+	f.Comment("the providers of the Values.")
+	f.Func().Params(recv.Clone()).Id("Validate").Params().Error().Block(checks...)
+
+	for i, valueFunc := range valueFuncs {
+		f.Line()
+		f.Func().Params(recv.Clone()).Id(valueFunc.funcName()).
+			Params(jen.Id("options").Op(" ...").Qual(diutil.PkgPath, "Option")).
+			Qual(diutil.PkgPath, "Value").Types(typeStts[i]).
+			Block(
+				jen.Return().Qual(diutil.PkgPath, "MustWithOptions").
+					Types(typeStts[i]).
+					Call(
+						jen.Id(containerName),
+						jen.Lit(valueFunc.key()),
+						jen.Id("options").Op("..."),
+					),
+			)
 	}
 
 	return nil
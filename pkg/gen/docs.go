@@ -0,0 +1,631 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+//nolint:depguard
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandremahdhaoui/di/pkg/diutil"
+	"go/ast"
+	"go/token"
+	"go/types"
+	htmltemplate "html/template"
+	"io"
+	pathpkg "path"
+	"path/filepath"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	docsFormatMarkdown = "markdown"
+	docsFormatHTML     = "html"
+)
+
+// +controllertools:marker:generateHelp:category="object"
+
+// DocsGenerator writes a human-readable documentation of the wiring of the packages: the Containers, the Values bound
+// to each Container with their types, the packages producing and consuming them, and the doc comments of the markers.
+//
+// Containers are declared by container markers, or by package-level vars initialized with di.New. The producers of a
+// Value are the packages calling its setters or the Set method of its ValueFunc, the consumers are the packages
+// referencing its ValueFunc otherwise.
+//
+// The documentation is not associated with a package: it is written to the directory of the output rule, e.g.
+// output:docs:dir=., as DI.md or DI.html. The packages of several modules, e.g. of a go.work, are documented per
+// module: the documentation of each module is written to the directory of the module, relative to the closest
+// directory containing all the modules, e.g. a/DI.md and b/DI.md for the modules of the directories a and b.
+type DocsGenerator struct {
+	// Format specifies the format of the documentation: markdown or html. The documentation is written in markdown by
+	// default.
+	Format string `marker:",optional"`
+}
+
+func (DocsGenerator) RegisterMarkers(into *markers.Registry) error {
+	if err := markers.RegisterAll(into, ContainerMarkerDefinition, ValueFuncMarkerDefinition); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	into.AddHelp(ContainerMarkerDefinition, markers.SimpleHelp("object", ""))
+	into.AddHelp(ValueFuncMarkerDefinition, markers.SimpleHelp("object", ""))
+
+	return nil
+}
+
+func (g DocsGenerator) Generate(ctx *genall.GenerationContext) error {
+	var (
+		filename string
+		render   func(w io.Writer, docs wiringDocs) error
+	)
+
+	switch g.Format {
+	case "", docsFormatMarkdown:
+		filename, render = "DI.md", renderMarkdownDocs
+	case docsFormatHTML:
+		filename, render = "DI.html", renderHTMLDocs
+	default:
+		return fmt.Errorf("unknown docs format %q, expected %s or %s", g.Format, docsFormatMarkdown, docsFormatHTML)
+	}
+
+	manifest := collectManifest(ctx)
+
+	for _, root := range ctx.Roots {
+		manifest.Containers = append(manifest.Containers, newContainers(root)...)
+	}
+
+	// the references are collected from all the roots, thus the packages of the other modules are listed too.
+	refs := collectReferences(ctx.Roots, manifest.Values)
+
+	for _, module := range docsModules(ctx.Roots) {
+		docs := newWiringDocs(module.roots, module.manifest(manifest), refs)
+
+		if err := writeDocs(ctx, filepath.Join(module.dir, filename), docs, render); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// docsModule is a module documented by the DocsGenerator.
+type docsModule struct {
+	// dir is the directory of the module, relative to the closest directory containing all the documented modules.
+	dir   string
+	roots []*loader.Package
+}
+
+// docsModules groups the roots by module, sorted by directory. The roots out of a module are documented with the
+// module of the closest directory containing all the documented modules, if any.
+func docsModules(roots []*loader.Package) []docsModule {
+	common := ""
+
+	for _, root := range roots {
+		switch dir := moduleDir(root); {
+		case dir == "":
+		case common == "":
+			common = dir
+		default:
+			for !isWithin(common, dir) {
+				common = filepath.Dir(common)
+			}
+		}
+	}
+
+	byDir := make(map[string]*docsModule)
+	modules := make([]string, 0)
+
+	for _, root := range roots {
+		dir := "."
+		if moduleDir := moduleDir(root); moduleDir != "" {
+			dir, _ = filepath.Rel(common, moduleDir)
+		}
+
+		if _, ok := byDir[dir]; !ok {
+			byDir[dir] = &docsModule{dir: dir, roots: make([]*loader.Package, 0)}
+			modules = append(modules, dir)
+		}
+
+		byDir[dir].roots = append(byDir[dir].roots, root)
+	}
+
+	sort.Strings(modules)
+
+	grouped := make([]docsModule, 0, len(modules))
+	for _, dir := range modules {
+		grouped = append(grouped, *byDir[dir])
+	}
+
+	return grouped
+}
+
+// isWithin returns true if path is dir or one of its descendants.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// manifest returns the Containers and the Values of manifest declared by the packages of the module.
+func (m docsModule) manifest(manifest Manifest) Manifest {
+	pkgs := make(map[string]bool, len(m.roots))
+	for _, root := range m.roots {
+		pkgs[root.PkgPath] = true
+	}
+
+	filtered := Manifest{Containers: make([]ContainerManifest, 0), Values: make([]ValueManifest, 0)}
+
+	for _, c := range manifest.Containers {
+		if pkgs[c.Package] {
+			filtered.Containers = append(filtered.Containers, c)
+		}
+	}
+
+	for _, v := range manifest.Values {
+		if pkgs[v.Package] {
+			filtered.Values = append(filtered.Values, v)
+		}
+	}
+
+	return filtered
+}
+
+// writeDocs renders the docs to the file at path, relative to the output rule.
+func writeDocs(ctx *genall.GenerationContext, path string, docs wiringDocs,
+	render func(w io.Writer, docs wiringDocs) error,
+) error {
+	buffer := &bytes.Buffer{}
+	if err := render(buffer, docs); err != nil {
+		return err
+	}
+
+	out, err := ctx.Open(nil, path)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	n, err := out.Write(buffer.Bytes())
+	if err == nil && n < buffer.Len() {
+		err = io.ErrShortWrite
+	}
+
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err //nolint:wrapcheck
+}
+
+// wiringDocs is the data of the documentation templates.
+type wiringDocs struct {
+	// Modules are the paths of the modules of the documented packages.
+	Modules    []string
+	Containers []containerDocs
+}
+
+type containerDocs struct {
+	ContainerManifest
+	// Declared is false if the Container is referenced by ValueFuncs, but not declared in the documented packages.
+	Declared bool
+	Values   []valueDocs
+}
+
+type valueDocs struct {
+	ValueManifest
+	// RelativeType is the type of the Value, relative to its package.
+	RelativeType string
+	// FuncRef is the ValueFunc, qualified with its package if it is not the package of the Container.
+	FuncRef   string
+	Producers []string
+	Consumers []string
+}
+
+// newWiringDocs groups the Values of manifest by Container. The Values bound to the di.DefaultContainer come first.
+func newWiringDocs(roots []*loader.Package, manifest Manifest, refs map[valueRef]*references) wiringDocs {
+	docs := wiringDocs{Modules: make([]string, 0), Containers: make([]containerDocs, 0)}
+	seen := make(map[string]bool)
+
+	for _, root := range roots {
+		if module := modulePath(root); module != "" && !seen[module] {
+			seen[module] = true
+			docs.Modules = append(docs.Modules, module)
+		}
+	}
+
+	sort.Strings(docs.Modules)
+
+	byKey := make(map[string]*containerDocs)
+	keys := make([]string, 0)
+
+	container := func(key string, manifest ContainerManifest, declared bool) *containerDocs {
+		if c, ok := byKey[key]; ok {
+			return c
+		}
+
+		byKey[key] = &containerDocs{ContainerManifest: manifest, Declared: declared, Values: make([]valueDocs, 0)}
+		keys = append(keys, key)
+
+		return byKey[key]
+	}
+
+	for _, c := range manifest.Containers {
+		container(c.Package+"."+c.Name, c, true)
+	}
+
+	for _, v := range manifest.Values {
+		key := v.Package + "." + v.Container
+		c := ContainerManifest{Name: v.Container, Package: v.Package} //nolint:exhaustruct
+
+		switch {
+		case v.ContainerPackage != "":
+			key = v.ContainerPackage + "." + v.Container
+			c = ContainerManifest{Name: v.Container, Package: v.ContainerPackage} //nolint:exhaustruct
+		case v.Container == "":
+			key = diutil.PkgPath + ".DefaultContainer"
+			c = ContainerManifest{Name: "DefaultContainer", Package: diutil.PkgPath, Exported: true} //nolint:exhaustruct
+		}
+
+		ref := refs[valueRef{pkg: v.Package, name: v.Func}]
+
+		funcRef := v.Func
+		if v.Package != c.Package && v.Container != "" {
+			funcRef = v.Package + "." + v.Func
+		}
+
+		container(key, c, v.Container == "").Values = append(byKey[key].Values, valueDocs{
+			ValueManifest: v,
+			RelativeType:  strings.ReplaceAll(v.Type, v.Package+".", ""),
+			FuncRef:       funcRef,
+			Producers:     ref.sortedProducers(),
+			Consumers:     ref.sortedConsumers(),
+		})
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := byKey[keys[i]], byKey[keys[j]]
+
+		if (a.Package == diutil.PkgPath) != (b.Package == diutil.PkgPath) {
+			return a.Package == diutil.PkgPath
+		}
+
+		return a.Package < b.Package || (a.Package == b.Package && a.Name < b.Name)
+	})
+
+	for _, key := range keys {
+		docs.Containers = append(docs.Containers, *byKey[key])
+	}
+
+	return docs
+}
+
+// newContainers returns the Containers declared by the package-level vars of root initialized with di.New.
+func newContainers(root *loader.Package) []ContainerManifest {
+	moduleDir := moduleDir(root)
+	containers := make([]ContainerManifest, 0)
+
+	for _, file := range root.Syntax {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.VAR {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+				valueSpec := spec.(*ast.ValueSpec) //nolint:forcetypeassert
+
+				doc := valueSpec.Doc
+				if doc == nil && len(genDecl.Specs) == 1 {
+					doc = genDecl.Doc
+				}
+
+				for i, value := range valueSpec.Values {
+					if i >= len(valueSpec.Names) || !isNewContainerCall(root, file, value) {
+						continue
+					}
+
+					containers = append(containers, ContainerManifest{
+						Name:     valueSpec.Names[i].Name,
+						Package:  root.PkgPath,
+						Exported: valueSpec.Names[i].IsExported(),
+						Typed:    false,
+						Doc:      strings.Join(strings.Fields(doc.Text()), " "),
+						Position: sourcePosition(root, valueSpec.Names[i].Pos(), moduleDir),
+					})
+				}
+			}
+		}
+	}
+
+	return containers
+}
+
+// isNewContainerCall reports if expr is a call to di.New with a string literal.
+func isNewContainerCall(root *loader.Package, file *ast.File, expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+
+	if lit, ok := call.Args[0].(*ast.BasicLit); !ok || lit.Kind != token.STRING {
+		return false
+	}
+
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "New" {
+		return false
+	}
+
+	x, ok := sel.X.(*ast.Ident)
+
+	return ok && importPathOf(root, file, x) == diutil.PkgPath
+}
+
+// valueRef identifies the ValueFunc name of the package pkg.
+type valueRef struct {
+	pkg, name string
+}
+
+// references are the packages producing and consuming a Value.
+type references struct {
+	producers, consumers map[string]bool
+}
+
+// sortedProducers returns the sorted producers of r, which may be nil.
+func (r *references) sortedProducers() []string {
+	if r == nil {
+		return make([]string, 0)
+	}
+
+	return sortedKeys(r.producers)
+}
+
+// sortedConsumers returns the sorted consumers of r, which may be nil.
+func (r *references) sortedConsumers() []string {
+	if r == nil {
+		return make([]string, 0)
+	}
+
+	return sortedKeys(r.consumers)
+}
+
+// sortedKeys returns the sorted keys of m.
+func sortedKeys[V any](m map[string]V) []string {
+	sl := make([]string, 0, len(m))
+	for key := range m {
+		sl = append(sl, key)
+	}
+
+	sort.Strings(sl)
+
+	return sl
+}
+
+// collectReferences returns the packages of roots producing and consuming the values.
+//
+// The generated files are not loaded, thus the references to the ValueFuncs are found syntactically: they are the
+// identifiers that are not resolved by the type checker, or the selectors of the packages declaring the ValueFuncs.
+func collectReferences(roots []*loader.Package, values []ValueManifest) map[valueRef]*references {
+	type target struct {
+		ref      valueRef
+		producer bool
+	}
+
+	targets := make(map[valueRef]target)
+
+	for _, v := range values {
+		ref := valueRef{pkg: v.Package, name: v.Func}
+		targets[ref] = target{ref: ref, producer: false}
+
+		for _, prefix := range []string{"Set", "MustSet", "Init", "InstallFake"} {
+			targets[valueRef{pkg: v.Package, name: prefix + v.Func}] = target{ref: ref, producer: true}
+		}
+	}
+
+	refs := make(map[valueRef]*references)
+
+	for _, root := range roots {
+		root.NeedTypesInfo()
+
+		for _, file := range root.Syntax {
+			// setters are the ValueFuncs called to access the Set method of the Value, e.g. Port().Set(8080).
+			setters := make(map[ast.Expr]bool)
+
+			record := func(pkg string, ident *ast.Ident, node ast.Expr) {
+				t, ok := targets[valueRef{pkg: pkg, name: ident.Name}]
+				if !ok {
+					return
+				}
+
+				ref, ok := refs[t.ref]
+				if !ok {
+					ref = &references{producers: make(map[string]bool), consumers: make(map[string]bool)}
+					refs[t.ref] = ref
+				}
+
+				if t.producer || setters[node] {
+					ref.producers[root.PkgPath] = true
+				} else {
+					ref.consumers[root.PkgPath] = true
+				}
+			}
+
+			ast.Inspect(file, func(node ast.Node) bool {
+				switch n := node.(type) {
+				case *ast.SelectorExpr:
+					if call, ok := n.X.(*ast.CallExpr); ok && n.Sel.Name == "Set" {
+						setters[call.Fun] = true
+					}
+
+					if x, ok := n.X.(*ast.Ident); ok {
+						if path := importPathOf(root, file, x); path != "" {
+							record(path, n.Sel, n)
+
+							return false
+						}
+					}
+				case *ast.Ident:
+					if root.TypesInfo.Uses[n] == nil && root.TypesInfo.Defs[n] == nil {
+						record(root.PkgPath, n, n)
+					}
+				}
+
+				return true
+			})
+		}
+	}
+
+	return refs
+}
+
+// importPathOf returns the path of the package imported by file as x, or an empty string if x is not a package name.
+func importPathOf(root *loader.Package, file *ast.File, x *ast.Ident) string {
+	if pkgName, ok := root.TypesInfo.Uses[x].(*types.PkgName); ok {
+		return pkgName.Imported().Path()
+	}
+
+	if root.TypesInfo.Uses[x] != nil {
+		return ""
+	}
+
+	// the imports that cannot be type checked are matched by name.
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		if (spec.Name != nil && spec.Name.Name == x.Name) || (spec.Name == nil && pathpkg.Base(path) == x.Name) {
+			return path
+		}
+	}
+
+	return ""
+}
+
+func renderMarkdownDocs(w io.Writer, docs wiringDocs) error {
+	tmpl, err := template.New("DI.md").Funcs(template.FuncMap{
+		"join": strings.Join,
+		"cell": func(s string) string { return strings.ReplaceAll(s, "|", `\|`) },
+	}).Parse(markdownDocsTemplate)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return tmpl.Execute(w, docs) //nolint:wrapcheck
+}
+
+func renderHTMLDocs(w io.Writer, docs wiringDocs) error {
+	tmpl, err := htmltemplate.New("DI.html").Funcs(htmltemplate.FuncMap{
+		"join": strings.Join,
+	}).Parse(htmlDocsTemplate)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return tmpl.Execute(w, docs) //nolint:wrapcheck
+}
+
+const markdownDocsTemplate = `<!-- Code generated by di-gen. DO NOT EDIT. -->
+
+# Dependency injection wiring
+{{- with .Modules }}
+
+Module: {{ range $i, $module := . }}{{ if $i }}, {{ end }}` + "`{{ $module }}`" + `{{ end }}
+{{- end }}
+{{ range .Containers }}
+## {{ .Name }}
+
+- Package: ` + "`{{ .Package }}`" + `
+{{- if .Position.File }}
+- Declared at: ` + "`{{ .Position.File }}:{{ .Position.Line }}`" + `
+{{- else if not .Declared }}
+- Declared at: not declared in the documented packages
+{{- end }}
+{{- if .Exported }}
+- Exported
+{{- end }}
+{{- if .Typed }}
+- Typed
+{{- end }}
+{{- with .Doc }}
+
+{{ . }}
+{{- end }}
+{{ if .Values }}
+| Value | Type | ValueFunc | Producers | Consumers | Description |
+|-------|------|-----------|-----------|-----------|-------------|
+{{- range .Values }}
+| ` + "`{{ .Name }}`" + ` | ` + "`{{ .RelativeType }}`" + ` | ` + "`{{ .FuncRef }}`" + ` ({{ .Position.File }}:{{ .Position.Line }}) | ` +
+	`{{ join .Producers "<br/>" }} | {{ join .Consumers "<br/>" }} | {{ cell .Doc }} |
+{{- end }}
+{{ else }}
+No Values are bound to this Container.
+{{ end }}
+{{- end }}`
+
+const htmlDocsTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="generator" content="di-gen">
+<title>Dependency injection wiring</title>
+</head>
+<body>
+<h1>Dependency injection wiring</h1>
+{{- with .Modules }}
+<p>Module: {{ join . ", " }}</p>
+{{- end }}
+{{- range .Containers }}
+<h2>{{ .Name }}</h2>
+<ul>
+<li>Package: <code>{{ .Package }}</code></li>
+{{- if .Position.File }}
+<li>Declared at: <code>{{ .Position.File }}:{{ .Position.Line }}</code></li>
+{{- else if not .Declared }}
+<li>Declared at: not declared in the documented packages</li>
+{{- end }}
+{{- if .Exported }}
+<li>Exported</li>
+{{- end }}
+{{- if .Typed }}
+<li>Typed</li>
+{{- end }}
+</ul>
+{{- with .Doc }}
+<p>{{ . }}</p>
+{{- end }}
+{{- if .Values }}
+<table>
+<tr><th>Value</th><th>Type</th><th>ValueFunc</th><th>Producers</th><th>Consumers</th><th>Description</th></tr>
+{{- range .Values }}
+<tr><td><code>{{ .Name }}</code></td><td><code>{{ .RelativeType }}</code></td>` +
+	`<td><code>{{ .FuncRef }}</code> ({{ .Position.File }}:{{ .Position.Line }})</td>` +
+	`<td>{{ join .Producers ", " }}</td><td>{{ join .Consumers ", " }}</td><td>{{ .Doc }}</td></tr>
+{{- end }}
+</table>
+{{- else }}
+<p>No Values are bound to this Container.</p>
+{{- end }}
+{{- end }}
+</body>
+</html>
+`
@@ -0,0 +1,248 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+//nolint:depguard
+import (
+	"bytes"
+	"github.com/alexandremahdhaoui/di/pkg/diutil"
+	"github.com/dave/jennifer/jen"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sort"
+)
+
+//go:generate go run sigs.k8s.io/controller-tools/cmd/helpgen generate:headerFile=../../hack/boilerplate.go.txt,year=2023
+
+// +controllertools:marker:generateHelp:category="object"
+
+// EntrypointGenerator generates the entrypoint of the containers declared in each package, by the container marker or
+// by a di.New var, including the unexported ones:
+//
+//   - The BuildContainers() func, building every container of the package.
+//
+//   - The ValidateContainers() error func, returning an error if any Value declared by the package is not registered
+//     with its type in its container, without calling the providers of the Values. The Values are declared by the
+//     valuefunc markers, including the ones bound to the default container or to the container of another package,
+//     and by the +di:provide markers.
+//
+// Thus, main can build and check the wiring of a package without accessing its unexported containers.
+type EntrypointGenerator struct {
+	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	// The header is a text/template, see HeaderData. Without a header file, the header names the generated package.
+	HeaderFile string `marker:",optional"`
+
+	// Year specifies the year to substitute for " YEAR" and {{ .Year }} in the header file.
+	Year string `marker:",optional"`
+
+	// Filename specifies the name of the generated file. The file is named zz_generated.di.entrypoint.go by default.
+	Filename string `marker:",optional"`
+
+	// BuildTag specifies a build constraint expression, e.g. "!wasm", that must be satisfied to build the generated
+	// file, in addition to !ignore_autogenerated.
+	BuildTag string `marker:",optional"`
+}
+
+func (EntrypointGenerator) RegisterMarkers(into *markers.Registry) error {
+	if err := markers.RegisterAll(into, ContainerMarkerDefinition, ValueFuncMarkerDefinition,
+		ProvideMarkerDefinition, InjectMarkerDefinition); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	into.AddHelp(ContainerMarkerDefinition, markers.SimpleHelp("object", ""))
+	into.AddHelp(ValueFuncMarkerDefinition, markers.SimpleHelp("object", ""))
+	into.AddHelp(ProvideMarkerDefinition, markers.SimpleHelp("object", ""))
+	into.AddHelp(InjectMarkerDefinition, markers.SimpleHelp("object", ""))
+
+	return nil
+}
+
+func (g EntrypointGenerator) Generate(ctx *genall.GenerationContext) error {
+	for _, root := range ctx.Roots {
+		root.NeedTypesInfo()
+
+		markerSet, err := markers.PackageMarkers(ctx.Collector, root)
+		if err != nil {
+			root.AddError(err)
+		}
+
+		if errs := append(validateMarkers(ctx.Collector.Registry, root), validateEntrypoint(root)...); len(errs) > 0 {
+			addErrors(root, errs...)
+
+			continue
+		}
+
+		decls, err := collectProvided(ctx.Collector, root)
+		if err != nil {
+			addErrors(root, err)
+
+			continue
+		}
+
+		containers := make([]string, 0)
+		for name := range newContainerDecls(root) {
+			containers = append(containers, name)
+		}
+
+		for _, markerValue := range markerSet[ContainerMarkerDefinition.Name] {
+			container := markerValue.(Container) //nolint:forcetypeassert
+			containers = append(containers, container.nameWithExportedCasing())
+		}
+
+		if len(containers) == 0 {
+			continue
+		}
+
+		sort.Strings(containers)
+
+		checks, err := entrypointChecks(root, markerSet[ValueFuncMarkerDefinition.Name], decls)
+		if err != nil {
+			root.AddError(err)
+
+			continue
+		}
+
+		// We create one zz_generated.di.entrypoint.go per package
+		// Thus we also instantiate one jen.File per package.
+		f := jen.NewFilePath(root.PkgPath) //nolint:varnamelen
+		entrypointCode(f, containers, checks)
+
+		buffer := &bytes.Buffer{}
+		if err := f.Render(buffer); err != nil {
+			root.AddError(err)
+
+			return err //nolint:wrapcheck
+		}
+
+		if err := generateFile(generateFileOptions{
+			buffer:     buffer,
+			generator:  EntrypointMarkerName,
+			ctx:        ctx,
+			filename:   outputFilename(g.Filename, EntrypointMarkerName),
+			buildTag:   g.BuildTag,
+			headerFile: g.HeaderFile,
+			year:       g.Year,
+			root:       root,
+		}); err != nil {
+			root.AddError(err)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// entrypointCheck is a Value checked by ValidateContainers.
+type entrypointCheck struct {
+	// container is the name of the di.Container of the Value relative to the package, e.g.: shared.Shared.
+	container     string
+	containerCode *jen.Statement
+	typeCode      *jen.Statement
+	key           string
+}
+
+// entrypointChecks returns the Values declared by the valueFuncMarkers and the provided decls of root, sorted by
+// container and key.
+func entrypointChecks(root *loader.Package, valueFuncMarkers []interface{}, decls *providedDecls,
+) ([]entrypointCheck, error) {
+	r := newTypeResolver(root)
+	checks := make([]entrypointCheck, 0, len(valueFuncMarkers)+len(decls.values))
+
+	for _, markerValue := range valueFuncMarkers {
+		valueFunc := markerValue.(ValueFunc) //nolint:forcetypeassert
+
+		typeStt, err := valueFunc.typeCode(r)
+		if err != nil {
+			return nil, err
+		}
+
+		checks = append(checks, entrypointCheck{
+			container:     valueFunc.containerName(root.PkgPath),
+			containerCode: valueFunc.containerCode(),
+			typeCode:      typeStt,
+			key:           valueFunc.key(),
+		})
+	}
+
+	for _, value := range decls.values {
+		typeStt, err := typeCode(value.typ)
+		if err != nil {
+			return nil, loader.ErrFromNode(err, value.node)
+		}
+
+		valueFunc := &ValueFunc{Container: value.container} //nolint:exhaustruct
+		checks = append(checks, entrypointCheck{
+			container:     valueFunc.containerName(root.PkgPath),
+			containerCode: valueFunc.containerCode(),
+			typeCode:      typeStt,
+			key:           value.name,
+		})
+	}
+
+	sort.SliceStable(checks, func(i, j int) bool {
+		if checks[i].container != checks[j].container {
+			return checks[i].container < checks[j].container
+		}
+
+		return checks[i].key < checks[j].key
+	})
+
+	return checks, nil
+}
+
+// entrypointCode generates the entrypoint of the containers:
+//
+//	func BuildContainers() {
+//		App.Build()
+//		cache.Build()
+//	}
+//
+//	func ValidateContainers() error {
+//		errs := make([]error, 0)
+//		if err := di.Check[int](App, "Port"); err != nil {
+//			errs = append(errs, err)
+//		}
+//		return errors.Join(errs...)
+//	}
+func entrypointCode(f *jen.File, containers []string, checks []entrypointCheck) {
+	builds := make([]jen.Code, 0, len(containers))
+	for _, containerName := range containers {
+		builds = append(builds, jen.Id(containerName).Dot("Build").Call())
+	}
+
+	body := []jen.Code{jen.Id("errs").Op(":=").Make(jen.Index().Error(), jen.Lit(0))}
+	for _, check := range checks {
+		body = append(body, jen.If(
+			jen.Err().Op(":=").Qual(diutil.PkgPath, "Check").Types(check.typeCode).
+				Call(check.containerCode, jen.Lit(check.key)),
+			jen.Err().Op("!=").Nil(),
+		).Block(
+			jen.Id("errs").Op("=").Append(jen.Id("errs"), jen.Err()),
+		))
+	}
+
+	body = append(body, jen.Return(jen.Qual("errors", "Join").Call(jen.Id("errs").Op("..."))))
+
+	f.Comment("BuildContainers builds the containers of the package.")
+	f.Func().Id("BuildContainers").Params().Block(builds...)
+
+	f.Comment("ValidateContainers returns an error if any Value declared by the package is not registered with its type")
+	f.Comment("in its container, without calling the providers of the Values.")
+	f.Func().Id("ValidateContainers").Params().Error().Block(body...)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+import (
+	"fmt"
+	"github.com/dave/jennifer/jen"
+	"go/types"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// ResolveType resolves the type expression expr against the package at path, like the ValueFunc markers of the
+// package do. The type is qualified with the import paths of its packages, e.g.: map[go/token.Pos]*go/ast.File.
+func ResolveType(path, expr string, typeImport *string) (string, error) {
+	typ, err := resolveType(path, expr, typeImport)
+	if err != nil {
+		return "", err
+	}
+
+	return types.TypeString(typ, nil), nil
+}
+
+// TypeCode resolves the type expression expr against the package at path like ResolveType does, and returns the
+// code generated for the type.
+func TypeCode(path, expr string, typeImport *string) (string, error) {
+	typ, err := resolveType(path, expr, typeImport)
+	if err != nil {
+		return "", err
+	}
+
+	stt, err := typeCode(typ)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%#v", jen.Var().Id("v").Add(stt)), nil
+}
+
+func resolveType(path, expr string, typeImport *string) (types.Type, error) {
+	roots, err := loader.LoadRoots(path)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if len(roots) != 1 {
+		return nil, fmt.Errorf("expected a single package at %s, got %d", path, len(roots))
+	}
+
+	roots[0].NeedTypesInfo()
+
+	return newTypeResolver(roots[0]).resolve(expr, typeImport)
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+//nolint:depguard
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandremahdhaoui/di/pkg/diutil"
+	"github.com/dave/jennifer/jen"
+	"go/types"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+//go:generate go run sigs.k8s.io/controller-tools/cmd/helpgen generate:headerFile=../../hack/boilerplate.go.txt,year=2023
+
+// +controllertools:marker:generateHelp:category="object"
+
+// FakeGenerator generates fake implementations of the interface types of the ValueFuncs marked with fake=true.
+//
+// For a ValueFunc named Name, the FakeGenerator generates:
+//
+//   - The FakeName struct implementing the interface. For each Method of the interface, FakeName records the
+//     arguments of the calls in MethodCalls, and calls the optional MethodStub func. The zero values are returned if
+//     MethodStub is nil.
+//
+//   - The InstallFakeName(fake *FakeName) error func, setting the fake as the Value of the ValueFunc in its container.
+type FakeGenerator struct {
+	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	// The header is a text/template, see HeaderData. Without a header file, the header names the generated package.
+	HeaderFile string `marker:",optional"`
+
+	// Year specifies the year to substitute for " YEAR" and {{ .Year }} in the header file.
+	Year string `marker:",optional"`
+
+	// Filename specifies the name of the generated file. The file is named zz_generated.di.fake.go by default.
+	Filename string `marker:",optional"`
+
+	// BuildTag specifies a build constraint expression, e.g. "!wasm", that must be satisfied to build the generated
+	// file, in addition to !ignore_autogenerated.
+	BuildTag string `marker:",optional"`
+}
+
+func (FakeGenerator) RegisterMarkers(into *markers.Registry) error {
+	// ContainerMarkerDefinition is registered to validate the containers of the ValueFuncs.
+	if err := markers.RegisterAll(into, ValueFuncMarkerDefinition, ContainerMarkerDefinition); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	into.AddHelp(ValueFuncMarkerDefinition, markers.SimpleHelp("object", ""))
+
+	return nil
+}
+
+func (g FakeGenerator) Generate(ctx *genall.GenerationContext) error {
+	for _, root := range ctx.Roots {
+		root.NeedTypesInfo()
+
+		markerSet, err := markers.PackageMarkers(ctx.Collector, root)
+		if err != nil {
+			root.AddError(err)
+		}
+
+		if errs := validateMarkers(ctx.Collector.Registry, root); len(errs) > 0 {
+			addErrors(root, errs...)
+
+			continue
+		}
+
+		markerValues := markerSet[ValueFuncMarkerDefinition.Name]
+		sortMarkerValues(markerValues, func(value interface{}) string {
+			valueFunc := value.(ValueFunc) //nolint:forcetypeassert
+
+			return valueFunc.funcName()
+		})
+
+		// We create one zz_generated.di.fake.go per package
+		// Thus we also instantiate one jen.File per package.
+		f := jen.NewFilePath(root.PkgPath) //nolint:varnamelen
+		resolver := newTypeResolver(root)
+		hasFakes, hasErrors := false, false
+
+		for _, markerValue := range markerValues {
+			valueFunc := markerValue.(ValueFunc) //nolint:forcetypeassert
+			if !valueFunc.hasFake() {
+				continue
+			}
+
+			code, err := fakeCode(root, resolver, valueFunc)
+			if err != nil {
+				root.AddError(err)
+
+				hasErrors = true
+
+				continue
+			}
+
+			for _, c := range code {
+				f.Line()
+				f.Add(c)
+			}
+
+			hasFakes = true
+		}
+
+		if !hasFakes || hasErrors {
+			continue
+		}
+
+		buffer := &bytes.Buffer{}
+		if err := f.Render(buffer); err != nil {
+			root.AddError(err)
+
+			return err //nolint:wrapcheck
+		}
+
+		if err := generateFile(generateFileOptions{
+			buffer:     buffer,
+			generator:  FakeMarkerName,
+			ctx:        ctx,
+			filename:   outputFilename(g.Filename, FakeMarkerName),
+			buildTag:   g.BuildTag,
+			headerFile: g.HeaderFile,
+			year:       g.Year,
+			root:       root,
+		}); err != nil {
+			root.AddError(err)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fakeCode returns the code of the fake of the interface type of valueFunc, and of its install func.
+func fakeCode(root *loader.Package, r *typeResolver, valueFunc ValueFunc) ([]jen.Code, error) {
+	typ, err := r.resolve(valueFunc.Type, valueFunc.TypeImport)
+	if err != nil {
+		return nil, fmt.Errorf("valuefunc %s: %w", valueFunc.Name, err)
+	}
+
+	iface, ok := typ.Underlying().(*types.Interface)
+	if !ok {
+		return nil, fmt.Errorf("valuefunc %s: cannot fake %s: not an interface type", valueFunc.Name, typ.String())
+	}
+
+	typeStt, err := typeCode(typ)
+	if err != nil {
+		return nil, fmt.Errorf("valuefunc %s: %w", valueFunc.Name, err)
+	}
+
+	fakeName := "Fake" + valueFunc.funcName()
+	fields := []jen.Code{jen.Id("mu").Qual("sync", "Mutex")}
+	code := make([]jen.Code, 0)
+
+	for i := 0; i < iface.NumMethods(); i++ {
+		method := iface.Method(i)
+		if !method.Exported() && method.Pkg() != root.Types {
+			return nil, fmt.Errorf("valuefunc %s: cannot fake %s: unexported method %s", valueFunc.Name,
+				typ.String(), method.Name())
+		}
+
+		methodFields, methodCode, err := fakeMethodCode(fakeName, method)
+		if err != nil {
+			return nil, fmt.Errorf("valuefunc %s: %w", valueFunc.Name, err)
+		}
+
+		fields = append(fields, jen.Line())
+		fields = append(fields, methodFields...)
+		code = append(code, methodCode...)
+	}
+
+	code = append([]jen.Code{
+		// var _ Type = (*FakeName)(nil)
+		jen.Var().Id("_").Add(typeStt).Op("=").Parens(jen.Op("*").Id(fakeName)).Call(jen.Nil()),
+		jen.Commentf("%s is a fake implementation of %s recording its calls.", fakeName,
+			types.TypeString(typ, types.RelativeTo(root.Types))).Line().
+			Type().Id(fakeName).Struct(fields...),
+	}, code...)
+
+	// func InstallFakeName(fake *FakeName) error {
+	// 	var v Type = fake
+	//
+	// 	return di.Set[Type](ContainerName, di.NewValue[Type]("Name", &v))
+	// }
+	installName := "Install" + fakeName
+	code = append(code, jen.Commentf("%s sets fake as the Value %q.", installName, valueFunc.key()).Line().
+		Func().Id(installName).Params(jen.Id("fake").Op("*").Id(fakeName)).Error().Block(
+		jen.Var().Id("v").Add(typeStt).Op("=").Id("fake"),
+		jen.Line(),
+		jen.Return().Qual(diutil.PkgPath, "Set").Types(typeStt).Call(
+			valueFunc.containerCode(),
+			jen.Qual(diutil.PkgPath, "NewValue").Types(typeStt).Call(jen.Lit(valueFunc.key()), jen.Op("&").Id("v")),
+		),
+	))
+
+	return code, nil
+}
+
+// fakeMethodCode returns the fields of the fake recording the calls to method, the struct of the calls and the
+// implementation of method.
+//
+//	type FakeNameMethodCall struct {
+//		Arg0 string
+//	}
+//
+//	func (f *FakeName) Method(arg0 string) (r0 error) {
+//		f.mu.Lock()
+//		f.MethodCalls = append(f.MethodCalls, FakeNameMethodCall{Arg0: arg0})
+//		stub := f.MethodStub
+//		f.mu.Unlock()
+//
+//		if stub != nil {
+//			return stub(arg0)
+//		}
+//
+//		return
+//	}
+func fakeMethodCode(fakeName string, method *types.Func) ([]jen.Code, []jen.Code, error) {
+	sig := method.Type().(*types.Signature) //nolint:forcetypeassert
+	name := method.Name()
+	callName := fakeName + name + "Call"
+
+	stubType, err := typeCode(sig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paramTypes, err := tupleCode(sig.Params(), sig.Variadic())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resultTypes, err := tupleCode(sig.Results(), false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	params := make([]jen.Code, 0, len(paramTypes))
+	args := make([]jen.Code, 0, len(paramTypes))
+	callFields := make([]jen.Code, 0, len(paramTypes))
+	callValues := jen.Dict{}
+
+	for i, paramType := range paramTypes {
+		arg := fmt.Sprintf("arg%d", i)
+		params = append(params, jen.Id(arg).Add(paramType))
+
+		fieldType, err := typeCode(sig.Params().At(i).Type())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		callFields = append(callFields, jen.Id(fmt.Sprintf("Arg%d", i)).Add(fieldType))
+		callValues[jen.Id(fmt.Sprintf("Arg%d", i))] = jen.Id(arg)
+
+		if sig.Variadic() && i == len(paramTypes)-1 {
+			args = append(args, jen.Id(arg).Op("..."))
+		} else {
+			args = append(args, jen.Id(arg))
+		}
+	}
+
+	results := make([]jen.Code, 0, len(resultTypes))
+	for i, resultType := range resultTypes {
+		results = append(results, jen.Id(fmt.Sprintf("r%d", i)).Add(resultType))
+	}
+
+	stubCall := jen.Id("stub").Call(args...)
+
+	var stubBlock jen.Code = jen.Return(stubCall)
+	if len(results) == 0 {
+		stubBlock = stubCall
+	}
+
+	fields := []jen.Code{
+		jen.Commentf("%sStub (optional) is called by %s.", name, name),
+		jen.Id(name + "Stub").Add(stubType),
+		jen.Commentf("%sCalls records the arguments of the calls to %s.", name, name),
+		jen.Id(name + "Calls").Index().Id(callName),
+	}
+
+	code := []jen.Code{
+		jen.Commentf("%s records the arguments of a call to %s.%s.", callName, fakeName, name).Line().
+			Type().Id(callName).Struct(callFields...),
+		jen.Func().Params(jen.Id("f").Op("*").Id(fakeName)).Id(name).Params(params...).Params(results...).Block(
+			jen.Id("f").Dot("mu").Dot("Lock").Call(),
+			jen.Id("f").Dot(name+"Calls").Op("=").Append(jen.Id("f").Dot(name+"Calls"), jen.Id(callName).Values(callValues)),
+			jen.Id("stub").Op(":=").Id("f").Dot(name+"Stub"),
+			jen.Id("f").Dot("mu").Dot("Unlock").Call(),
+			jen.Line(),
+			jen.If(jen.Id("stub").Op("!=").Nil()).Block(stubBlock),
+			jen.Do(func(s *jen.Statement) {
+				if len(results) > 0 {
+					s.Line().Return()
+				}
+			}),
+		),
+	}
+
+	return fields, code, nil
+}
@@ -0,0 +1,361 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexandremahdhaoui/di/pkg/gen"
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+	"golang.org/x/tools/go/packages"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+const (
+	goldenDir    = "testdata/wiring"
+	goldenHeader = "../../hack/boilerplate.go.txt"
+	genPkgPath   = "github.com/alexandremahdhaoui/di/pkg/gen/"
+)
+
+// memoryOutput is a genall.OutputRule keeping the generated files in memory.
+type memoryOutput map[string]*bytes.Buffer
+
+type memoryFile struct {
+	*bytes.Buffer
+}
+
+func (o memoryOutput) Open(_ *loader.Package, path string) (io.WriteCloser, error) {
+	o[path] = &bytes.Buffer{}
+
+	return memoryFile{o[path]}, nil
+}
+
+func (memoryFile) Close() error {
+	return nil
+}
+
+// generate runs generator against the golden package and returns the generated files by name.
+func generate(generator genall.Generator) map[string]string {
+	files, errs := run(generator, "./"+goldenDir)
+	Expect(errs).To(BeEmpty())
+
+	return files
+}
+
+// run runs generator against the package at path, and returns the generated files by name and the errors of the
+// package, but the type errors.
+func run(generator genall.Generator, path string) (map[string]string, []error) {
+	return runAll(path, generator)
+}
+
+// runAll runs the generators against the same packages of path, like genall.Runtime does.
+func runAll(path string, generators ...genall.Generator) (map[string]string, []error) {
+	roots, err := loader.LoadRoots(path)
+	Expect(err).NotTo(HaveOccurred())
+
+	return runRoots(roots, generators...)
+}
+
+// runRoots runs the generators against the same roots, like genall.Runtime does.
+func runRoots(roots []*loader.Package, generators ...genall.Generator) (map[string]string, []error) {
+	registry := &markers.Registry{}
+	for _, generator := range generators {
+		Expect(generator.RegisterMarkers(registry)).To(Succeed())
+	}
+
+	output := memoryOutput{}
+	for _, generator := range generators {
+		Expect(generator.Generate(&genall.GenerationContext{
+			Collector:  &markers.Collector{Registry: registry},
+			Roots:      roots,
+			Checker:    &loader.TypeChecker{},
+			OutputRule: output,
+			InputRule:  genall.InputFromFileSystem,
+		})).To(Succeed())
+	}
+
+	errs := make([]error, 0)
+
+	for _, root := range roots {
+		for _, err := range root.Errors {
+			// type errors are skipped, like genall.Runtime does.
+			var typeErr packages.Error
+			if errors.As(err, &typeErr) && typeErr.Kind == packages.TypeError {
+				continue
+			}
+
+			errs = append(errs, err)
+		}
+	}
+
+	files := make(map[string]string, len(output))
+	for path, buffer := range output {
+		files[path] = buffer.String()
+	}
+
+	return files, errs
+}
+
+// typedTest tests the Validate method of the generated TypedApp.
+const typedTest = `package wiring
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexandremahdhaoui/di"
+)
+
+func TestTypedAppValidate(t *testing.T) {
+	previous := App
+	App = di.New(previous.Name())
+	t.Cleanup(func() { App = previous })
+
+	if err := (TypedApp{}).Validate(); err == nil || !strings.Contains(err.Error(), "\"Port\"") {
+		t.Fatalf("expected the missing Value Port to be reported, got %v", err)
+	}
+
+	Handlers(di.InitializeOption)
+	Notifier(di.InitializeOption)
+	Port(di.InitializeOption)
+
+	if err := (TypedApp{}).Validate(); err != nil {
+		t.Fatalf("expected the Values to be valid, got %v", err)
+	}
+
+	di.MustWithOptions[string](App, "Port", di.InitializeOption)
+
+	if err := (TypedApp{}).Validate(); err == nil || !strings.Contains(err.Error(), "not a Value[int]") {
+		t.Fatalf("expected the Value Port of another type to be reported, got %v", err)
+	}
+}
+`
+
+// entrypointTest tests the ValidateContainers func of the generated entrypoint.
+const entrypointTest = `package wiring
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexandremahdhaoui/di"
+	"github.com/alexandremahdhaoui/di/pkg/gen/testdata/wiring/shared"
+)
+
+func TestValidateContainers(t *testing.T) {
+	previousApp, previousCache, previousRegistry := App, cache, Registry
+	previousDefault, previousShared := di.DefaultContainer, shared.Shared
+	t.Cleanup(func() {
+		App, cache, Registry = previousApp, previousCache, previousRegistry
+		di.DefaultContainer, shared.Shared = previousDefault, previousShared
+	})
+
+	App, cache, Registry = di.New("app"), di.New("cache"), di.New("registry")
+	di.DefaultContainer, shared.Shared = di.New("default"), di.New("shared")
+
+	err := ValidateContainers()
+	for _, key := range []string{"Greeting", "Region", "Pairs", "ConfigValue", "RouterValue"} {
+		if err == nil || !strings.Contains(err.Error(), "\""+key+"\"") {
+			t.Fatalf("expected the missing Value %s to be reported, got %v", key, err)
+		}
+	}
+
+	Port(di.InitializeOption)
+	Handlers(di.InitializeOption)
+	Pairs(di.InitializeOption)
+	Greeting(di.InitializeOption)
+	Token(di.InitializeOption)
+	Region(di.InitializeOption)
+	Notifier(di.InitializeOption)
+	Files(di.InitializeOption)
+	HandlerValue(di.InitializeOption)
+
+	di.MustInstall(di.DefaultContainer, di.NewModule("default").Provides(ConfigValueProvider, DBValueProvider))
+	di.MustInstall(App, di.NewModule("app").Provides(SrvProvider, RouterValueProvider))
+
+	if err := ValidateContainers(); err != nil {
+		t.Fatalf("expected the Values to be valid, got %v", err)
+	}
+}
+`
+
+// goTestGenerated copies the golden package into the testdata with the files generated by generators and the test
+// files by name, then runs go test against it.
+func goTestGenerated(testFiles map[string]string, generators ...genall.Generator) {
+	dir, err := os.MkdirTemp("testdata", "tmp-")
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(os.RemoveAll, dir)
+	Expect(os.Mkdir(filepath.Join(dir, "shared"), 0o700)).To(Succeed())
+
+	// the copied package imports the copied shared package.
+	write := func(path, content string) {
+		content = strings.ReplaceAll(content, genPkgPath+goldenDir+"/shared", genPkgPath+dir+"/shared")
+		Expect(os.WriteFile(filepath.Join(dir, path), []byte(content), 0o600)).To(Succeed())
+	}
+
+	for _, pkg := range []string{"", "shared"} {
+		sources, err := filepath.Glob(filepath.Join(goldenDir, pkg, "*.go"))
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, source := range sources {
+			content, err := os.ReadFile(source)
+			Expect(err).NotTo(HaveOccurred())
+			write(filepath.Join(pkg, filepath.Base(source)), string(content))
+		}
+	}
+
+	shared, errs := run(gen.ContainerGenerator{}, "./"+goldenDir+"/shared")
+	Expect(errs).To(BeEmpty())
+
+	for name, content := range shared {
+		write(filepath.Join("shared", name), content)
+	}
+
+	files, errs := runAll("./"+goldenDir, generators...)
+	Expect(errs).To(BeEmpty())
+
+	for name, content := range files {
+		write(name, content)
+	}
+
+	for name, content := range testFiles {
+		write(name, content)
+	}
+
+	out, err := exec.Command("go", "test", "./"+dir+"/...").CombinedOutput()
+	Expect(err).NotTo(HaveOccurred(), string(out))
+}
+
+var _ = Describe("Generators", func() {
+	DescribeTable("should generate the golden files",
+		func(generator genall.Generator, filenames ...string) {
+			files := generate(generator)
+			Expect(files).To(HaveLen(len(filenames)))
+
+			By("generating byte-identical files across runs")
+			Expect(generate(generator)).To(Equal(files))
+
+			for _, filename := range filenames {
+				Expect(files).To(HaveKey(filename))
+
+				golden := filepath.Join(goldenDir, filename+".golden")
+				if *update {
+					Expect(os.WriteFile(golden, []byte(files[filename]), 0o600)).To(Succeed())
+				}
+
+				expected, err := os.ReadFile(golden)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files[filename]).To(Equal(string(expected)))
+			}
+		},
+		Entry("container", gen.ContainerGenerator{HeaderFile: goldenHeader, Year: "2023"},
+			"zz_generated.di.container.go"),
+		Entry("container split by container",
+			gen.ContainerGenerator{HeaderFile: goldenHeader, Year: "2023", SplitByContainer: true},
+			"zz_generated.di.container.App.go", "zz_generated.di.container.cache.go"),
+		Entry("valuefunc", gen.ValueFuncGenerator{HeaderFile: goldenHeader, Year: "2023"},
+			"zz_generated.di.valuefunc.go"),
+		Entry("valuefunc with tests", gen.ValueFuncGenerator{HeaderFile: goldenHeader, Year: "2023", Tests: true},
+			"zz_generated.di.valuefunc.go", "zz_generated.di.valuefunc_test.go"),
+		Entry("valuefunc split by container",
+			gen.ValueFuncGenerator{HeaderFile: goldenHeader, Year: "2023", SplitByContainer: true},
+			"zz_generated.di.valuefunc.App.go", "zz_generated.di.valuefunc.DefaultContainer.go",
+			"zz_generated.di.valuefunc.Registry.go", "zz_generated.di.valuefunc.cache.go",
+			"zz_generated.di.valuefunc.shared.Shared.go"),
+		Entry("valuefunc with a filename and a build tag",
+			gen.ValueFuncGenerator{HeaderFile: goldenHeader, Year: "2023", Filename: "di_gen.go", BuildTag: "!wasm || js"},
+			"di_gen.go"),
+		Entry("valuefunc without a header file", gen.ValueFuncGenerator{Filename: "default_header.go"},
+			"default_header.go"),
+		Entry("provide", gen.ProvideGenerator{HeaderFile: goldenHeader, Year: "2023"},
+			"zz_generated.di.provide.go"),
+		Entry("fake", gen.FakeGenerator{HeaderFile: goldenHeader, Year: "2023"},
+			"zz_generated.di.fake.go"),
+		Entry("injector", gen.InjectorGenerator{HeaderFile: goldenHeader, Year: "2023"},
+			"zz_generated.di.injector.go"),
+		Entry("entrypoint", gen.EntrypointGenerator{HeaderFile: goldenHeader, Year: "2023"},
+			"zz_generated.di.entrypoint.go"),
+		Entry("manifest", gen.ManifestGenerator{}, "di-manifest.yaml"),
+		Entry("manifest in json", gen.ManifestGenerator{Format: "json"}, "di-manifest.json"),
+		Entry("docs", gen.DocsGenerator{}, "DI.md"),
+		Entry("docs in html", gen.DocsGenerator{Format: "html"}, "DI.html"),
+	)
+
+	It("should document the packages of each module in the directory of the module", func() {
+		dir := filepath.Join("testdata", "modules")
+
+		// the modules of the go.work cannot be loaded with -mod=mod.
+		roots, err := loader.LoadRootsWithConfig(&packages.Config{ //nolint:exhaustruct
+			Dir: dir,
+			Env: append(os.Environ(), "GOFLAGS="),
+		}, "./a/...", "./b/...")
+		Expect(err).NotTo(HaveOccurred())
+
+		files, errs := runRoots(roots, gen.DocsGenerator{})
+		Expect(errs).To(BeEmpty())
+		Expect(files).To(HaveLen(2))
+
+		for _, filename := range []string{filepath.Join("a", "DI.md"), filepath.Join("b", "DI.md")} {
+			golden := filepath.Join(dir, filename+".golden")
+			if *update {
+				Expect(os.WriteFile(golden, []byte(files[filename]), 0o600)).To(Succeed())
+			}
+
+			expected, err := os.ReadFile(golden)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files[filename]).To(Equal(string(expected)))
+		}
+	})
+
+	It("should generate ValueFunc tests passing against the generated ValueFuncs", func() {
+		goTestGenerated(nil, gen.ContainerGenerator{}, gen.ValueFuncGenerator{Tests: true})
+	})
+
+	It("should generate typed containers validating the registered Values", func() {
+		goTestGenerated(map[string]string{"typed_test.go": typedTest}, gen.ContainerGenerator{}, gen.ValueFuncGenerator{})
+	})
+
+	It("should generate an entrypoint validating the Values declared by the package", func() {
+		goTestGenerated(map[string]string{"entrypoint_test.go": entrypointTest},
+			gen.ContainerGenerator{}, gen.ValueFuncGenerator{}, gen.ProvideGenerator{}, gen.EntrypointGenerator{})
+	})
+
+	It("should generate the same files when the provide and injector generators run together", func() {
+		provide := gen.ProvideGenerator{HeaderFile: goldenHeader, Year: "2023"}
+		injector := gen.InjectorGenerator{HeaderFile: goldenHeader, Year: "2023"}
+
+		files, errs := runAll("./"+goldenDir, provide, injector)
+		Expect(errs).To(BeEmpty())
+
+		// the +di:provide and +di:injector:provide markers of the same funcs do not generate more code.
+		expected := generate(provide)
+		for name, content := range generate(injector) {
+			expected[name] = content
+		}
+
+		Expect(files).To(Equal(expected))
+	})
+})
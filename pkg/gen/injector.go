@@ -0,0 +1,470 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+//nolint:depguard
+import (
+	"bytes"
+	"fmt"
+	"github.com/dave/jennifer/jen"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//go:generate go run sigs.k8s.io/controller-tools/cmd/helpgen generate:headerFile=../../hack/boilerplate.go.txt,year=2023
+
+// Injector marks a constructor function as the root of an object graph. The InjectorGenerator emits an injector func
+// calling the providers of the constructor's dependencies in dependency order, then the constructor itself.
+type Injector struct {
+	// Name identifies the generated injector func.
+	Name string
+}
+
+// InjectorProvide marks a constructor function as the provider of the type of its first result, called by the
+// injector funcs. Unlike +di:provide, it does not declare a di.Value.
+type InjectorProvide struct{}
+
+var (
+	InjectorMarkerDefinition = markers.Must( //nolint:gochecknoglobals
+		markers.MakeDefinition(markerName(DIMarkerName, InjectorMarkerName), markers.DescribesType, Injector{}),
+	)
+
+	InjectorProvideMarkerDefinition = markers.Must( //nolint:gochecknoglobals
+		markers.MakeDefinition(markerName(markerName(DIMarkerName, InjectorMarkerName), ProvideMarkerName),
+			markers.DescribesType, InjectorProvide{}),
+	)
+)
+
+// +controllertools:marker:generateHelp:category="object"
+
+// InjectorGenerator generates injector funcs wiring constructors in dependency order with plain Go calls.
+//
+// Markers:
+//
+//   - +di:injector:provide on a func marks it as the provider of the type of its first result.
+//     The func may return an error as its second result.
+//     The +di:provide markers of the provide generator are ignored: a func marked with both markers is called by the
+//     injector funcs, and provides a di.Value.
+//
+//   - +di:injector:name=<name> on a func generates an injector func named <name>, which calls the providers of the
+//     func's dependencies in dependency order, then the func itself, and returns its result and an error.
+//     Each dependency must be provided by exactly one +di:injector:provide func of the package.
+type InjectorGenerator struct {
+	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	// The header is a text/template, see HeaderData. Without a header file, the header names the generated package.
+	HeaderFile string `marker:",optional"`
+
+	// Year specifies the year to substitute for " YEAR" and {{ .Year }} in the header file.
+	Year string `marker:",optional"`
+
+	// Filename specifies the name of the generated file. The file is named zz_generated.di.injector.go by default.
+	Filename string `marker:",optional"`
+
+	// BuildTag specifies a build constraint expression, e.g. "!wasm", that must be satisfied to build the generated
+	// file, in addition to !ignore_autogenerated.
+	BuildTag string `marker:",optional"`
+}
+
+func (InjectorGenerator) RegisterMarkers(into *markers.Registry) error {
+	if err := markers.RegisterAll(into, InjectorProvideMarkerDefinition, InjectorMarkerDefinition); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	into.AddHelp(InjectorProvideMarkerDefinition, markers.SimpleHelp("object", ""))
+	into.AddHelp(InjectorMarkerDefinition, markers.SimpleHelp("object", ""))
+
+	return nil
+}
+
+func (g InjectorGenerator) Generate(ctx *genall.GenerationContext) error {
+	for _, root := range ctx.Roots {
+		root.NeedTypesInfo()
+
+		providers, injectors := collectProviders(root, ctx.Collector.Registry)
+		if len(injectors) == 0 {
+			continue
+		}
+
+		sort.SliceStable(injectors, func(i, j int) bool {
+			return injectors[i].name < injectors[j].name
+		})
+
+		// We create one zz_generated.di.injector.go per package
+		// Thus we also instantiate one jen.File per package.
+		f := jen.NewFilePath(root.PkgPath) //nolint:varnamelen
+		hasErrors := false
+
+		for _, injector := range injectors {
+			code, err := newWiring(root, providers).injectorCode(injector)
+			if err != nil {
+				root.AddError(loader.ErrFromNode(err, injector.decl))
+
+				hasErrors = true
+
+				continue
+			}
+
+			f.Add(code)
+		}
+
+		if hasErrors {
+			continue
+		}
+
+		buffer := &bytes.Buffer{}
+		if err := f.Render(buffer); err != nil {
+			root.AddError(err)
+
+			return err //nolint:wrapcheck
+		}
+
+		if err := generateFile(generateFileOptions{
+			buffer:     buffer,
+			generator:  InjectorMarkerName,
+			ctx:        ctx,
+			filename:   outputFilename(g.Filename, InjectorMarkerName),
+			buildTag:   g.BuildTag,
+			headerFile: g.HeaderFile,
+			year:       g.Year,
+			root:       root,
+		}); err != nil {
+			root.AddError(err)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+type (
+	// provider is a constructor func marked with +di:provide, +di:injector:provide or +di:injector.
+	provider struct {
+		decl   *ast.FuncDecl
+		fn     *types.Func
+		sig    *types.Signature
+		result types.Type
+		// hasErr indicates if the constructor returns an error as its second result.
+		hasErr bool
+	}
+
+	injector struct {
+		*provider
+		name string
+	}
+
+	// wiring resolves the dependencies of an injector.
+	wiring struct {
+		root      *loader.Package
+		providers []*provider
+
+		order []*provider
+		state map[*provider]int
+	}
+)
+
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+// collectProviders returns the providers & injectors declared in the package, by order of declaration.
+func collectProviders(root *loader.Package, reg *markers.Registry) ([]*provider, []injector) {
+	providers := make([]*provider, 0)
+	injectors := make([]injector, 0)
+
+	for _, file := range root.Syntax {
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+
+			markerValues, err := funcMarkers(reg, funcDecl)
+			if err != nil {
+				root.AddError(err)
+
+				continue
+			}
+
+			injectorValues := markerValues[InjectorMarkerDefinition.Name]
+			provideValues := markerValues[InjectorProvideMarkerDefinition.Name]
+
+			if len(provideValues) == 0 && len(injectorValues) == 0 {
+				continue
+			}
+
+			p, err := newProvider(root, funcDecl)
+			if err != nil {
+				root.AddError(loader.ErrFromNode(err, funcDecl))
+
+				continue
+			}
+
+			if len(provideValues) > 0 {
+				providers = append(providers, p)
+			}
+
+			for _, value := range injectorValues {
+				injectors = append(injectors, injector{
+					provider: p,
+					name:     value.(Injector).Name, //nolint:forcetypeassert
+				})
+			}
+		}
+	}
+
+	return providers, injectors
+}
+
+func newProvider(root *loader.Package, decl *ast.FuncDecl) (*provider, error) {
+	fn, ok := root.TypesInfo.Defs[decl.Name].(*types.Func)
+	if !ok {
+		return nil, fmt.Errorf("cannot find type information of func %s", decl.Name.Name)
+	}
+
+	sig := fn.Type().(*types.Signature) //nolint:forcetypeassert
+
+	switch {
+	case sig.Recv() != nil:
+		return nil, fmt.Errorf("provider %s must be a func, not a method", fn.Name())
+	case sig.TypeParams().Len() > 0:
+		return nil, fmt.Errorf("provider %s must not have type parameters", fn.Name())
+	case sig.Variadic():
+		return nil, fmt.Errorf("provider %s must not be variadic", fn.Name())
+	}
+
+	results := sig.Results()
+	errorType := types.Universe.Lookup("error").Type()
+
+	switch {
+	case results.Len() == 1:
+		return &provider{decl: decl, fn: fn, sig: sig, result: results.At(0).Type()}, nil
+	case results.Len() == 2 && types.Identical(results.At(1).Type(), errorType): //nolint:gomnd
+		return &provider{decl: decl, fn: fn, sig: sig, result: results.At(0).Type(), hasErr: true}, nil
+	default:
+		return nil, fmt.Errorf("provider %s must return (T) or (T, error)", fn.Name())
+	}
+}
+
+func newWiring(root *loader.Package, providers []*provider) *wiring {
+	return &wiring{
+		root:      root,
+		providers: providers,
+		order:     make([]*provider, 0),
+		state:     make(map[*provider]int),
+	}
+}
+
+// providerFor returns the only provider of typ.
+func (w *wiring) providerFor(typ types.Type, requiredBy *provider) (*provider, error) {
+	candidates := make([]*provider, 0)
+
+	for _, p := range w.providers {
+		if types.Identical(p.result, typ) {
+			candidates = append(candidates, p)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("no provider found for %s, required by %s", w.typeString(typ), requiredBy.fn.Name())
+	case 1:
+		return candidates[0], nil
+	default:
+		names := make([]string, 0, len(candidates))
+		for _, p := range candidates {
+			names = append(names, p.fn.Name())
+		}
+
+		return nil, fmt.Errorf("ambiguous providers for %s, required by %s: %s",
+			w.typeString(typ), requiredBy.fn.Name(), strings.Join(names, ", "))
+	}
+}
+
+// visit appends p to the wiring order after its dependencies.
+func (w *wiring) visit(p *provider, path []string) error {
+	path = append(path, p.fn.Name())
+
+	switch w.state[p] {
+	case visited:
+		return nil
+	case visiting:
+		return fmt.Errorf("circular dependency between providers: %s", strings.Join(path, " -> "))
+	}
+
+	w.state[p] = visiting
+
+	for i := 0; i < p.sig.Params().Len(); i++ {
+		dependency, err := w.providerFor(p.sig.Params().At(i).Type(), p)
+		if err != nil {
+			return err
+		}
+
+		if err := w.visit(dependency, path); err != nil {
+			return err
+		}
+	}
+
+	w.state[p] = visited
+	w.order = append(w.order, p)
+
+	return nil
+}
+
+// injectorCode returns the code of the injector func.
+//
+//	func Name() (T, error) {
+//		a, err := NewA()
+//		if err != nil {
+//			return nil, err
+//		}
+//
+//		b := NewB(a)
+//
+//		return b, nil
+//	}
+func (w *wiring) injectorCode(inj injector) (jen.Code, error) {
+	if err := w.visit(inj.provider, nil); err != nil {
+		return nil, err
+	}
+
+	resultType, err := typeCode(inj.result)
+	if err != nil {
+		return nil, err
+	}
+
+	zero, err := zeroValueCode(inj.result)
+	if err != nil {
+		return nil, err
+	}
+
+	names := newVarNames(w.root)
+	vars := make(map[*provider]string, len(w.order))
+	body := make([]jen.Code, 0)
+	providerNames := make([]string, 0, len(w.order))
+
+	for _, p := range w.order {
+		vars[p] = names.next(p.result)
+		providerNames = append(providerNames, p.fn.Name())
+
+		args := make([]jen.Code, 0, p.sig.Params().Len())
+
+		for i := 0; i < p.sig.Params().Len(); i++ {
+			dependency, _ := w.providerFor(p.sig.Params().At(i).Type(), p)
+			args = append(args, jen.Id(vars[dependency]))
+		}
+
+		call := jen.Id(p.fn.Name()).Call(args...)
+
+		if !p.hasErr {
+			body = append(body, jen.Id(vars[p]).Op(":=").Add(call), jen.Line())
+
+			continue
+		}
+
+		body = append(body,
+			jen.List(jen.Id(vars[p]), jen.Err()).Op(":=").Add(call),
+			jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(zero, jen.Err())),
+			jen.Line(),
+		)
+	}
+
+	body = append(body, jen.Return(jen.Id(vars[inj.provider]), jen.Nil()))
+
+	return jen.Commentf("%s constructs %s by calling: %s.", inj.name, w.typeString(inj.result),
+		strings.Join(providerNames, ", ")).Line().
+		Func().Id(inj.name).Params().Parens(jen.List(resultType, jen.Error())).Block(body...).Line(), nil
+}
+
+func (w *wiring) typeString(typ types.Type) string {
+	return types.TypeString(typ, types.RelativeTo(w.root.Types))
+}
+
+// varNames generates variable names that do not shadow identifiers of the package scope, imported packages & Go
+// keywords.
+type varNames struct {
+	root *loader.Package
+	used map[string]bool
+}
+
+func newVarNames(root *loader.Package) *varNames {
+	used := map[string]bool{"err": true}
+
+	for _, imported := range root.Types.Imports() {
+		used[imported.Name()] = true
+	}
+
+	return &varNames{root: root, used: used}
+}
+
+func (n *varNames) next(typ types.Type) string {
+	base := varNameFromType(typ)
+
+	name := base
+	for i := 1; n.used[name] || token.IsKeyword(name) || n.root.Types.Scope().Lookup(name) != nil ||
+		types.Universe.Lookup(name) != nil; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+
+	n.used[name] = true
+
+	return name
+}
+
+// varNameFromType returns the lower camel case name of a type, e.g.: *sql.DB -> db, HTTPClient -> httpClient.
+func varNameFromType(typ types.Type) string {
+	for {
+		switch t := typ.(type) {
+		case *types.Pointer:
+			typ = t.Elem()
+
+			continue
+		case *types.Named:
+			return lowerCamel(t.Obj().Name())
+		}
+
+		return "v"
+	}
+}
+
+func lowerCamel(s string) string {
+	r := []rune(s)
+
+	upper := 0
+	for upper < len(r) && unicode.IsUpper(r[upper]) {
+		upper++
+	}
+
+	switch {
+	case upper == 0:
+		return s
+	case upper == 1 || upper == len(r):
+		return strings.ToLower(string(r[:upper])) + string(r[upper:])
+	default:
+		// keep the last upper rune of an acronym, as it begins the next word: HTTPClient -> httpClient
+		return strings.ToLower(string(r[:upper-1])) + string(r[upper-1:])
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+//nolint:depguard
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sort"
+)
+
+const (
+	manifestFormatYAML = "yaml"
+	manifestFormatJSON = "json"
+)
+
+// +controllertools:marker:generateHelp:category="object"
+
+// ManifestGenerator writes a manifest describing the Containers and ValueFuncs of all the packages, e.g. for tools
+// consuming the wiring without parsing Go.
+//
+// The manifest is not associated with a package: it is written to the directory of the output rule, e.g.
+// output:manifest:dir=./config, as di-manifest.yaml or di-manifest.json.
+type ManifestGenerator struct {
+	// Format specifies the format of the manifest: yaml or json. The manifest is written in yaml by default.
+	Format string `marker:",optional"`
+}
+
+// Manifest describes the Containers and ValueFuncs of a set of packages.
+type Manifest struct {
+	Containers []ContainerManifest `json:"containers"`
+	Values     []ValueManifest     `json:"values"`
+}
+
+// ContainerManifest describes a Container declared with the container marker.
+type ContainerManifest struct {
+	// Name is the name of the Container, i.e. its generated identifier.
+	Name string `json:"name"`
+	// Package is the import path of the package declaring the Container.
+	Package  string `json:"package"`
+	Exported bool   `json:"exported"`
+	Typed    bool   `json:"typed"`
+	// Doc is the comment preceding the marker.
+	Doc      string   `json:"doc,omitempty"`
+	Position Position `json:"position"`
+}
+
+// ValueManifest describes a Value declared with the valuefunc marker.
+type ValueManifest struct {
+	// Name is the name of the Value, i.e. its key in the Container.
+	Name string `json:"name"`
+	// Func is the identifier of the generated ValueFunc.
+	Func string `json:"func"`
+	// Package is the import path of the package declaring the ValueFunc.
+	Package string `json:"package"`
+	// Container is the name of the Container holding the Value. It is empty for the di.DefaultContainer.
+	Container string `json:"container,omitempty"`
+	// ContainerPackage is the import path of the package declaring the Container, if it is not Package.
+	ContainerPackage string `json:"containerPackage,omitempty"`
+	// Type is the type T of the Value[T], qualified with the import paths of its packages.
+	Type     string `json:"type"`
+	Exported bool   `json:"exported"`
+	// Doc is the comment preceding the marker.
+	Doc      string   `json:"doc,omitempty"`
+	Position Position `json:"position"`
+}
+
+// Position is the position of a marker in the sources.
+type Position struct {
+	// File is the path of the file, relative to the root of its module.
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+func (ManifestGenerator) RegisterMarkers(into *markers.Registry) error {
+	if err := markers.RegisterAll(into, ContainerMarkerDefinition, ValueFuncMarkerDefinition); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	into.AddHelp(ContainerMarkerDefinition, markers.SimpleHelp("object", ""))
+	into.AddHelp(ValueFuncMarkerDefinition, markers.SimpleHelp("object", ""))
+
+	return nil
+}
+
+func (g ManifestGenerator) Generate(ctx *genall.GenerationContext) error {
+	format := g.Format
+	if format == "" {
+		format = manifestFormatYAML
+	}
+
+	if format != manifestFormatYAML && format != manifestFormatJSON {
+		return fmt.Errorf("unknown manifest format %q, expected %s or %s", g.Format, manifestFormatYAML,
+			manifestFormatJSON)
+	}
+
+	manifest := collectManifest(ctx)
+	filename := "di-manifest." + format
+
+	if format == manifestFormatYAML {
+		return ctx.WriteYAML(filename, "", []interface{}{manifest}) //nolint:wrapcheck
+	}
+
+	return writeJSON(ctx, filename, manifest)
+}
+
+// collectManifest returns the sorted Manifest of the roots of ctx. The errors are added to the roots.
+func collectManifest(ctx *genall.GenerationContext) Manifest {
+	manifest := Manifest{Containers: make([]ContainerManifest, 0), Values: make([]ValueManifest, 0)}
+
+	for _, root := range ctx.Roots {
+		root.NeedTypesInfo()
+
+		for _, err := range manifestOf(ctx.Collector.Registry, root, &manifest) {
+			root.AddError(err)
+		}
+	}
+
+	sort.SliceStable(manifest.Containers, func(i, j int) bool {
+		a, b := manifest.Containers[i], manifest.Containers[j]
+
+		return a.Package < b.Package || (a.Package == b.Package && a.Name < b.Name)
+	})
+
+	sort.SliceStable(manifest.Values, func(i, j int) bool {
+		a, b := manifest.Values[i], manifest.Values[j]
+
+		return a.Package < b.Package || (a.Package == b.Package && a.Func < b.Func)
+	})
+
+	return manifest
+}
+
+// manifestOf appends the Containers and ValueFuncs of root to manifest.
+func manifestOf(reg *markers.Registry, root *loader.Package, manifest *Manifest) []error {
+	resolver := newTypeResolver(root)
+	pkgMarkers, errs := packageMarkers(reg, root)
+
+	for _, m := range pkgMarkers {
+		switch marker := m.value.(type) {
+		case Container:
+			manifest.Containers = append(manifest.Containers, ContainerManifest{
+				Name:     marker.nameWithExportedCasing(),
+				Package:  root.PkgPath,
+				Exported: marker.isExported(),
+				Typed:    marker.isTyped(),
+				Doc:      m.doc,
+				Position: m.position,
+			})
+		case ValueFunc:
+			typ, err := resolver.resolve(marker.Type, marker.TypeImport)
+			if err != nil {
+				errs = append(errs, loader.ErrFromNode(fmt.Errorf("valuefunc %s: %w", marker.Name, err), m.comment))
+
+				continue
+			}
+
+			container, containerPackage := "", ""
+			if path, ident := marker.containerRef(); marker.Container != nil {
+				container = ident
+
+				if path != "" && path != root.PkgPath {
+					containerPackage = path
+				}
+			}
+
+			manifest.Values = append(manifest.Values, ValueManifest{
+				Name:             marker.key(),
+				Func:             marker.funcName(),
+				Package:          root.PkgPath,
+				Container:        container,
+				ContainerPackage: containerPackage,
+				Type:             types.TypeString(typ, nil),
+				Exported:         marker.isExported(),
+				Doc:              m.doc,
+				Position:         m.position,
+			})
+		}
+	}
+
+	return errs
+}
+
+// writeJSON writes obj as indented JSON to the file itemPath, which is not associated with a package.
+func writeJSON(ctx *genall.GenerationContext, itemPath string, obj interface{}) error {
+	data, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	out, err := ctx.Open(nil, itemPath)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	data = append(data, '\n')
+
+	n, err := out.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err //nolint:wrapcheck
+}
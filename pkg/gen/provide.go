@@ -0,0 +1,684 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+//nolint:depguard
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandremahdhaoui/di/pkg/diutil"
+	"github.com/dave/jennifer/jen"
+	"go/ast"
+	"go/types"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//go:generate go run sigs.k8s.io/controller-tools/cmd/helpgen generate:headerFile=../../hack/boilerplate.go.txt,year=2023
+
+// Provide marks a type declaration or a constructor function as the provider of a di.Value.
+// The type T of the Value[T] is the declared type, or the type of the constructor's first result.
+type Provide struct {
+	// Name (optional) identifies the func used to access the Value, and the key of the Value.
+	// Name defaults to the name of the type followed by "Value", e.g.: ConfigValue.
+	Name *string
+	// Container (optional) specifies the di.Container that will be used to store the Value.
+	Container *string
+}
+
+// Inject marks a struct field to be set with a di.Value.
+type Inject struct {
+	// Name (optional) is the key of the injected Value.
+	// By default, the Value is the only Value of the package provided with the type of the field.
+	Name *string
+}
+
+var (
+	ProvideMarkerDefinition = markers.Must( //nolint:gochecknoglobals
+		markers.MakeDefinition(markerName(DIMarkerName, ProvideMarkerName), markers.DescribesType, Provide{}),
+	)
+
+	InjectMarkerDefinition = markers.Must( //nolint:gochecknoglobals
+		markers.MakeDefinition(markerName(DIMarkerName, InjectMarkerName), markers.DescribesField, Inject{}),
+	)
+)
+
+// +controllertools:marker:generateHelp:category="object"
+
+// ProvideGenerator generates ValueFuncs and di.Providers from Go declarations, instead of package markers.
+//
+// Markers:
+//
+//   - +di:provide on a type declaration generates the ValueFunc of a Value of this type.
+//     If the type is a struct with fields marked with +di:inject, a di.Provider constructing it is also generated.
+//
+//   - +di:provide on a constructor func generates the ValueFunc of a Value of the type of its first result, and a
+//     di.Provider calling the constructor with the Values of its parameters.
+//     The func may return an error as its second result.
+//
+//   - +di:inject on a struct field generates the InjectValues(c di.Container) error method of the struct, setting the
+//     field with the Value of its type, or the Value identified by name.
+//
+// The di.Providers and the InjectValues methods read the Values of their dependencies from c, the di.Container they
+// are installed in, or from the di.Container of the dependency when it is provided in another di.Container.
+//
+// Fields of +di:provide:
+//
+//   - Name (optional string) identifies the func used to access the Value, and the key of the Value.
+//     Name defaults to the name of the type followed by "Value", e.g.: ConfigValue.
+//
+//   - Container (optional string) specifies the di.Container that will be used to store the Value.
+//     Container resolves to a di.Container defined in the current pkg, or in another pkg when it is qualified with
+//     the import path of the pkg, e.g.: container=example.com/app/wiring.App.
+//     The default container is used by default.
+//
+// Fields of +di:inject:
+//
+//   - Name (optional string) is the key of the injected Value.
+//     By default, the Value is the only Value of the package provided with the type of the field.
+type ProvideGenerator struct {
+	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	// The header is a text/template, see HeaderData. Without a header file, the header names the generated package.
+	HeaderFile string `marker:",optional"`
+
+	// Year specifies the year to substitute for " YEAR" and {{ .Year }} in the header file.
+	Year string `marker:",optional"`
+
+	// Filename specifies the name of the generated file. The file is named zz_generated.di.provide.go by default.
+	Filename string `marker:",optional"`
+
+	// BuildTag specifies a build constraint expression, e.g. "!wasm", that must be satisfied to build the generated
+	// file, in addition to !ignore_autogenerated.
+	BuildTag string `marker:",optional"`
+}
+
+func (ProvideGenerator) RegisterMarkers(into *markers.Registry) error {
+	if err := markers.RegisterAll(into, ProvideMarkerDefinition, InjectMarkerDefinition); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	into.AddHelp(ProvideMarkerDefinition, markers.SimpleHelp("object", ""))
+	into.AddHelp(InjectMarkerDefinition, markers.SimpleHelp("object", ""))
+
+	return nil
+}
+
+func (g ProvideGenerator) Generate(ctx *genall.GenerationContext) error {
+	for _, root := range ctx.Roots {
+		root.NeedTypesInfo()
+
+		decls, err := collectProvided(ctx.Collector, root)
+		if err != nil {
+			root.AddError(err)
+
+			continue
+		}
+
+		if len(decls.values) == 0 && len(decls.targets) == 0 {
+			continue
+		}
+
+		code, err := decls.code()
+		if err != nil {
+			root.AddError(err)
+
+			continue
+		}
+
+		// We create one zz_generated.di.provide.go per package
+		// Thus we also instantiate one jen.File per package.
+		f := jen.NewFilePath(root.PkgPath) //nolint:varnamelen
+		for _, c := range code {
+			f.Line()
+			f.Add(c)
+		}
+
+		buffer := &bytes.Buffer{}
+		if err := f.Render(buffer); err != nil {
+			root.AddError(err)
+
+			return err //nolint:wrapcheck
+		}
+
+		if err := generateFile(generateFileOptions{
+			buffer:     buffer,
+			generator:  ProvideMarkerName,
+			ctx:        ctx,
+			filename:   outputFilename(g.Filename, ProvideMarkerName),
+			buildTag:   g.BuildTag,
+			headerFile: g.HeaderFile,
+			year:       g.Year,
+			root:       root,
+		}); err != nil {
+			root.AddError(err)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+type (
+	// providedValue is a Value declared by a type or a constructor func marked with +di:provide.
+	providedValue struct {
+		name      string
+		container *string
+		typ       types.Type
+		node      ast.Node
+
+		// constructor (optional) is the constructor func providing the Value.
+		constructor *provider
+		// target (optional) is the struct providing the Value, when it has injected fields.
+		target *injectTarget
+	}
+
+	// injectTarget is a struct type with fields marked with +di:inject.
+	injectTarget struct {
+		typeName *types.TypeName
+		fields   []injectedField
+	}
+
+	injectedField struct {
+		name string
+		typ  types.Type
+		key  *string
+		node ast.Node
+	}
+
+	providedDecls struct {
+		root    *loader.Package
+		values  []*providedValue
+		targets []*injectTarget
+	}
+)
+
+// collectProvided returns the Values & inject targets declared in the package, sorted by name.
+func collectProvided(collector *markers.Collector, root *loader.Package) (*providedDecls, error) {
+	decls := &providedDecls{root: root, values: make([]*providedValue, 0), targets: make([]*injectTarget, 0)}
+	errs := make([]error, 0)
+
+	if err := markers.EachType(collector, root, func(info *markers.TypeInfo) {
+		typeName, ok := root.TypesInfo.Defs[info.RawSpec.Name].(*types.TypeName)
+		if !ok {
+			return
+		}
+
+		target, err := newInjectTarget(root, typeName, info)
+		if err != nil {
+			errs = append(errs, err)
+
+			return
+		}
+
+		if target != nil {
+			decls.targets = append(decls.targets, target)
+		}
+
+		for _, markerValue := range info.Markers[ProvideMarkerDefinition.Name] {
+			if named, ok := typeName.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				errs = append(errs, loader.ErrFromNode(
+					fmt.Errorf("provided type %s must not have type parameters", typeName.Name()), info.RawSpec))
+
+				continue
+			}
+
+			value, err := newProvidedValue(markerValue.(Provide), typeName.Type(), info.RawSpec, target) //nolint:forcetypeassert,lll
+			if err != nil {
+				errs = append(errs, err)
+
+				continue
+			}
+
+			decls.values = append(decls.values, value)
+		}
+	}); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	for _, file := range root.Syntax {
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+
+			markerValues, err := funcMarkers(collector.Registry, funcDecl)
+			if err != nil {
+				errs = append(errs, err)
+
+				continue
+			}
+
+			for _, markerValue := range markerValues[ProvideMarkerDefinition.Name] {
+				constructor, err := newProvider(root, funcDecl)
+				if err != nil {
+					errs = append(errs, loader.ErrFromNode(err, funcDecl))
+
+					continue
+				}
+
+				value, err := newProvidedValue(markerValue.(Provide), constructor.result, funcDecl, nil) //nolint:forcetypeassert,lll
+				if err != nil {
+					errs = append(errs, err)
+
+					continue
+				}
+
+				value.constructor = constructor
+				decls.values = append(decls.values, value)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, loader.MaybeErrList(errs)
+	}
+
+	sort.SliceStable(decls.values, func(i, j int) bool {
+		return decls.values[i].name < decls.values[j].name
+	})
+	sort.SliceStable(decls.targets, func(i, j int) bool {
+		return decls.targets[i].typeName.Name() < decls.targets[j].typeName.Name()
+	})
+
+	return decls, nil
+}
+
+func newProvidedValue(marker Provide, typ types.Type, node ast.Node, target *injectTarget) (*providedValue, error) {
+	value := &providedValue{container: marker.Container, typ: typ, node: node, target: target}
+
+	if marker.Name != nil {
+		value.name = *marker.Name
+
+		return value, nil
+	}
+
+	named := typ
+	if ptr, ok := named.(*types.Pointer); ok {
+		named = ptr.Elem()
+	}
+
+	if named, ok := named.(*types.Named); ok {
+		value.name = named.Obj().Name() + "Value"
+
+		return value, nil
+	}
+
+	return nil, loader.ErrFromNode(fmt.Errorf("a name is required to provide a Value of type %s", typ.String()), node)
+}
+
+// newInjectTarget returns the inject target of the type described by info, or nil if none of its fields are marked
+// with +di:inject.
+func newInjectTarget(root *loader.Package, typeName *types.TypeName, info *markers.TypeInfo) (*injectTarget, error) {
+	fields := make([]injectedField, 0)
+
+	for _, field := range info.Fields {
+		markerValues := field.Markers[InjectMarkerDefinition.Name]
+		if len(markerValues) == 0 {
+			continue
+		}
+
+		if field.Name == "" {
+			return nil, loader.ErrFromNode(fmt.Errorf("embedded field of %s cannot be injected", typeName.Name()),
+				field.RawField)
+		}
+
+		typ := root.TypesInfo.TypeOf(field.RawField.Type)
+		if typ == nil {
+			return nil, loader.ErrFromNode(fmt.Errorf("cannot find type information of field %s", field.Name),
+				field.RawField)
+		}
+
+		fields = append(fields, injectedField{
+			name: field.Name,
+			typ:  typ,
+			key:  markerValues[0].(Inject).Name, //nolint:forcetypeassert
+			node: field.RawField,
+		})
+	}
+
+	if len(fields) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	return &injectTarget{typeName: typeName, fields: fields}, nil
+}
+
+// valueOf returns the only Value of the package provided with typ.
+func (d *providedDecls) valueOf(typ types.Type, requiredBy string) (*providedValue, error) {
+	candidates := make([]*providedValue, 0)
+	names := make([]string, 0)
+
+	for _, value := range d.values {
+		if types.Identical(value.typ, typ) {
+			candidates = append(candidates, value)
+			names = append(names, value.name)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("no Value provided for %s, required by %s", d.typeString(typ), requiredBy)
+	case 1:
+		return candidates[0], nil
+	default:
+		return nil, fmt.Errorf("ambiguous Values provided for %s, required by %s: %s",
+			d.typeString(typ), requiredBy, strings.Join(names, ", "))
+	}
+}
+
+// valueNamed returns the Value of the package provided with the key name, or nil if the Value is not provided by
+// the package.
+func (d *providedDecls) valueNamed(name string) *providedValue {
+	for _, value := range d.values {
+		if value.name == name {
+			return value
+		}
+	}
+
+	return nil
+}
+
+// containerOf returns the import path and the identifier of the di.Container holding the Values of container.
+func (d *providedDecls) containerOf(container *string) (string, string) {
+	path, ident := (&ValueFunc{Container: container}).containerRef() //nolint:exhaustruct
+	if path == "" {
+		path = d.root.PkgPath
+	}
+
+	return path, ident
+}
+
+// containerCode returns the code referencing the di.Container holding dependency, read by a Provider or an
+// InjectValues method installed in the di.Container of the Values of container: c if both di.Containers are the same
+// or if dependency is not provided by the package, the di.Container of dependency otherwise.
+func (d *providedDecls) containerCode(dependency *providedValue, container *string) *jen.Statement {
+	if dependency == nil {
+		return jen.Id("c")
+	}
+
+	path, ident := d.containerOf(dependency.container)
+	if otherPath, otherIdent := d.containerOf(container); path == otherPath && ident == otherIdent {
+		return jen.Id("c")
+	}
+
+	return (&ValueFunc{Container: dependency.container}).containerCode() //nolint:exhaustruct
+}
+
+// targetContainer returns the container of the Value provided by target, or nil if target is not provided.
+func (d *providedDecls) targetContainer(target *injectTarget) *string {
+	for _, value := range d.values {
+		if value.target == target && types.Identical(value.typ, target.typeName.Type()) {
+			return value.container
+		}
+	}
+
+	return nil
+}
+
+func (d *providedDecls) typeString(typ types.Type) string {
+	return types.TypeString(typ, types.RelativeTo(d.root.Types))
+}
+
+func (d *providedDecls) code() ([]jen.Code, error) {
+	code := make([]jen.Code, 0)
+	errs := make([]error, 0)
+
+	for _, value := range d.values {
+		valueCode, err := d.valueCode(value)
+		if err != nil {
+			errs = append(errs, loader.ErrFromNode(err, value.node))
+
+			continue
+		}
+
+		code = append(code, valueCode...)
+	}
+
+	for _, target := range d.targets {
+		targetCode, err := d.injectValuesCode(target)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		code = append(code, targetCode)
+	}
+
+	if len(errs) > 0 {
+		return nil, loader.MaybeErrList(errs)
+	}
+
+	return code, nil
+}
+
+// valueCode returns the ValueFunc of value, and its Provider if it has a constructor or a target.
+//
+//	func ConfigValue(options ...di.Option) di.Value[Config] {
+//		return di.MustWithOptions[Config](di.DefaultContainer, "ConfigValue", options...)
+//	}
+func (d *providedDecls) valueCode(value *providedValue) ([]jen.Code, error) {
+	typeStt, err := typeCode(value.typ)
+	if err != nil {
+		return nil, err
+	}
+
+	containerStt := (&ValueFunc{Container: value.container}).containerCode() //nolint:exhaustruct
+
+	code := []jen.Code{
+		jen.Func().Id(value.name).
+			Params(jen.Id("options").Op(" ...").Qual(diutil.PkgPath, "Option")).
+			Qual(diutil.PkgPath, "Value").Types(typeStt).
+			Block(
+				jen.Return().Qual(diutil.PkgPath, "MustWithOptions").
+					Types(typeStt).
+					Call(containerStt, jen.Lit(value.name), jen.Id("options").Op("...")),
+			),
+	}
+
+	var constructorCode []jen.Code
+
+	switch {
+	case value.constructor != nil:
+		constructorCode, err = d.constructorCode(value)
+	case value.target != nil && types.Identical(value.typ, value.target.typeName.Type()):
+		constructorCode, err = d.targetConstructorCode(value)
+	default:
+		return code, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// var ConfigValueProvider = di.NewProvider[Config]("ConfigValue", func(c di.Container) (Config, error) {...})
+	providerName := value.name + "Provider"
+	code = append(code,
+		jen.Commentf("%s provides the Value %q.", providerName, value.name).Line().Var().Id(providerName).Op("=").Qual(diutil.PkgPath, "NewProvider").Types(typeStt).Call(
+			jen.Lit(value.name),
+			jen.Func().Params(jen.Id("c").Qual(diutil.PkgPath, "Container")).
+				Parens(jen.List(typeStt.Clone(), jen.Error())).Block(constructorCode...),
+		),
+	)
+
+	return code, nil
+}
+
+// constructorCode returns the body of the Provider calling the constructor of value. The Values held by another
+// di.Container than value are read from their di.Container instead of c.
+//
+//	config, err := di.Get[Config](c, "ConfigValue")
+//	if err != nil {
+//		return nil, err
+//	}
+//
+//	return NewDB(config.MustValue())
+func (d *providedDecls) constructorCode(value *providedValue) ([]jen.Code, error) {
+	constructor := value.constructor
+
+	zero, err := zeroValueCode(value.typ)
+	if err != nil {
+		return nil, err
+	}
+
+	names := newVarNames(d.root)
+	names.used["c"] = true
+
+	body := make([]jen.Code, 0)
+	args := make([]jen.Code, 0, constructor.sig.Params().Len())
+
+	for i := 0; i < constructor.sig.Params().Len(); i++ {
+		typ := constructor.sig.Params().At(i).Type()
+
+		dependency, err := d.valueOf(typ, constructor.fn.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		getCode, err := getValueCode(typ, d.containerCode(dependency, value.container), dependency.name)
+		if err != nil {
+			return nil, err
+		}
+
+		name := names.next(typ)
+		args = append(args, jen.Id(name).Dot("MustValue").Call())
+		body = append(body,
+			jen.List(jen.Id(name), jen.Err()).Op(":=").Add(getCode),
+			jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(zero.Clone(), jen.Err())),
+			jen.Line(),
+		)
+	}
+
+	call := jen.Id(constructor.fn.Name()).Call(args...)
+	if constructor.hasErr {
+		return append(body, jen.Return(call)), nil
+	}
+
+	return append(body, jen.Return(call, jen.Nil())), nil
+}
+
+// targetConstructorCode returns the body of the Provider constructing the struct of value with its injected fields.
+//
+//	server := Server{}
+//	if err := server.InjectValues(c); err != nil {
+//		return Server{}, err
+//	}
+//
+//	return server, nil
+func (d *providedDecls) targetConstructorCode(value *providedValue) ([]jen.Code, error) {
+	typeStt, err := typeCode(value.typ)
+	if err != nil {
+		return nil, err
+	}
+
+	names := newVarNames(d.root)
+	names.used["c"] = true
+	name := names.next(value.typ)
+
+	return []jen.Code{
+		jen.Id(name).Op(":=").Add(typeStt.Clone().Values()),
+		jen.If(
+			jen.Err().Op(":=").Id(name).Dot("InjectValues").Call(jen.Id("c")),
+			jen.Err().Op("!=").Nil(),
+		).Block(jen.Return(typeStt.Clone().Values(), jen.Err())),
+		jen.Line(),
+		jen.Return(jen.Id(name), jen.Nil()),
+	}, nil
+}
+
+// injectValuesCode returns the InjectValues method of target. The Values held by another di.Container than the
+// Value provided by target are read from their di.Container instead of c.
+//
+//	func (s *Server) InjectValues(c di.Container) error {
+//		db, err := di.Get[*DB](c, "DBValue")
+//		if err != nil {
+//			return err
+//		}
+//
+//		s.DB = db.MustValue()
+//
+//		return nil
+//	}
+func (d *providedDecls) injectValuesCode(target *injectTarget) (jen.Code, error) {
+	names := newVarNames(d.root)
+	names.used["c"] = true
+
+	recv := strings.ToLower(string([]rune(target.typeName.Name())[0:1]))
+	if !unicode.IsLetter([]rune(recv)[0]) || names.used[recv] {
+		recv = "x"
+	}
+
+	names.used[recv] = true
+	body := make([]jen.Code, 0)
+	container := d.targetContainer(target)
+
+	for _, field := range target.fields {
+		var (
+			key        string
+			dependency *providedValue
+		)
+
+		if field.key != nil {
+			key, dependency = *field.key, d.valueNamed(*field.key)
+		} else {
+			var err error
+			if dependency, err = d.valueOf(field.typ, target.typeName.Name()+"."+field.name); err != nil {
+				return nil, loader.ErrFromNode(err, field.node)
+			}
+
+			key = dependency.name
+		}
+
+		getCode, err := getValueCode(field.typ, d.containerCode(dependency, container), key)
+		if err != nil {
+			return nil, loader.ErrFromNode(err, field.node)
+		}
+
+		name := names.next(field.typ)
+		body = append(body,
+			jen.List(jen.Id(name), jen.Err()).Op(":=").Add(getCode),
+			jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err())),
+			jen.Line(),
+			jen.Id(recv).Dot(field.name).Op("=").Id(name).Dot("MustValue").Call(),
+			jen.Line(),
+		)
+	}
+
+	body = append(body, jen.Return(jen.Nil()))
+
+	return jen.Commentf("InjectValues sets the fields of %s marked with +di:inject with the Values of c.",
+		target.typeName.Name()).Line().
+		Func().Params(jen.Id(recv).Op("*").Id(target.typeName.Name())).Id("InjectValues").
+		Params(jen.Id("c").Qual(diutil.PkgPath, "Container")).Error().Block(body...), nil
+}
+
+// getValueCode returns the code getting the Value identified by key from the container, e.g.:
+// di.Get[Config](c, "ConfigValue").
+func getValueCode(typ types.Type, container *jen.Statement, key string) (*jen.Statement, error) {
+	typeStt, err := typeCode(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	return jen.Qual(diutil.PkgPath, "Get").Types(typeStt).Call(container, jen.Lit(key)), nil
+}
@@ -0,0 +1,13 @@
+// Package entrypoint declares the identifiers generated by the entrypoint generator.
+package entrypoint
+
+import (
+	"github.com/alexandremahdhaoui/di"
+)
+
+var app = di.New("app")
+
+// BuildContainers collides with the generated func.
+func BuildContainers() {
+	app.Build()
+}
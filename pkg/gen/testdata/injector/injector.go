@@ -0,0 +1,26 @@
+// Package injector is the input of the tests of the interactions of the injector and provide markers.
+package injector
+
+type (
+	Config struct{}
+
+	DB struct{}
+
+	App struct{}
+)
+
+// NewConfig provides a di.Value, but is not called by the injectors.
+// +di:provide
+func NewConfig() Config {
+	return Config{}
+}
+
+// +di:injector:provide:name=Database
+func NewDB() *DB {
+	return &DB{}
+}
+
+// +di:injector:name=InitializeApp
+func NewApp(cfg Config) *App {
+	return &App{}
+}
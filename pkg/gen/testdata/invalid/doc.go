@@ -0,0 +1,14 @@
+// +di:container:name=app
+// +di:container:name=app
+// +di:container:name=registry,exported=true
+// +di:valuefunc:name=port,container=Missing,type=int
+// +di:valuefunc:name=host,container=app,type=string
+// +di:valuefunc:name=host,container=app,type=string
+// +di:valuefunc:name=host,container=Cache,type=string
+// +di:valuefunc:name=config,type=string
+// +di:valuefunc:name=timeout,container=Cache,type=int,setters=true
+// +di:valuefunc:name=region,container=github.com/alexandremahdhaoui/di/pkg/gen/testdata/wiring/shared.Missing,type=string
+// +di:valuefunc:name=zone,container=github.com/alexandremahdhaoui/di/pkg/gen/testdata/wiring/shared.internal,type=string
+
+// Package invalid is the input of the validation tests of the generators.
+package invalid
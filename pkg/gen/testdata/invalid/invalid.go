@@ -0,0 +1,19 @@
+package invalid
+
+import (
+	"github.com/alexandremahdhaoui/di"
+)
+
+var (
+	// Cache is a Container declared with di.New.
+	Cache = di.New("cache")
+
+	// Registry collides with the container marker registry.
+	Registry = di.New("registry")
+)
+
+// Config collides with the ValueFunc config.
+type Config struct{}
+
+// SetTimeout collides with the setter of the ValueFunc timeout.
+func SetTimeout() {}
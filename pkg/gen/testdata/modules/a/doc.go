@@ -0,0 +1,7 @@
+// App holds the Values of the module a.
+// +di:container:name=app,exported=true
+// Port is the port the server listens on.
+// +di:valuefunc:name=port,container=App,type=int
+
+// Package a is a package of the module a.
+package a
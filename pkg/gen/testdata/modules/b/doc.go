@@ -0,0 +1,5 @@
+// Name is the name of the module b.
+// +di:valuefunc:name=name,type=string
+
+// Package b is a package of the module b.
+package b
@@ -0,0 +1,10 @@
+// Package ast is named like go/ast, to test the resolution of the qualified identifiers.
+package ast
+
+type (
+	// File is named like go/ast.File.
+	File struct{}
+
+	// node cannot be used by the ValueFuncs of other packages.
+	node struct{}
+)
@@ -0,0 +1,22 @@
+package typeexpr
+
+import (
+	"github.com/alexandremahdhaoui/di/pkg/gen/testdata/typeexpr/ast"
+)
+
+type (
+	// Pair is a generic type of the package.
+	Pair[K comparable, V any] struct {
+		Key   K
+		Value V
+	}
+
+	// Plain is not a generic type.
+	Plain struct{}
+
+	// local can be used by the ValueFuncs of the package only.
+	local struct{}
+
+	// Node names a type of the imported ast package, which is not go/ast.
+	Node = ast.File
+)
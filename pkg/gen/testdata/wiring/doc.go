@@ -0,0 +1,16 @@
+// +di:container:name=cache
+// App holds the Values of the application.
+// +di:container:name=app,exported=true,typed=true
+// Port is the port the Server listens on.
+// +di:valuefunc:name=port,container=App,type=int,setters=true
+// +di:valuefunc:name=handlers,container=App,type="map[string][]*Handler"
+// +di:valuefunc:name=pairs,container=cache,type="Pair[string, *Handler]"
+// +di:valuefunc:name=greeting,type=string
+// +di:valuefunc:name=token,container=Registry,type=string
+// Region is held by the container of another package.
+// +di:valuefunc:name=region,container=github.com/alexandremahdhaoui/di/pkg/gen/testdata/wiring/shared.Shared,type=string
+// +di:valuefunc:name=notifier,container=App,type=Sender,fake=true
+// +di:valuefunc:name=files,type="map[token.Pos]*ast.File",typeImport="go/ast;go/token"
+
+// Package wiring is the input of the golden tests of the generators.
+package wiring
@@ -0,0 +1,20 @@
+package wiring
+
+import (
+	"github.com/alexandremahdhaoui/di"
+)
+
+// Registry is a Container declared without a container marker.
+var Registry = di.New("registry")
+
+// Listen consumes the Port.
+func Listen() int {
+	return Port().MustValue()
+}
+
+// Configure produces the Port and the Greeting.
+func Configure() {
+	MustSetPort(8080)
+
+	_ = Greeting().Set("hello")
+}
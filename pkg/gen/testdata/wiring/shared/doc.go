@@ -0,0 +1,4 @@
+// +di:container:name=shared,exported=true
+
+// Package shared declares a container shared by the golden packages.
+package shared
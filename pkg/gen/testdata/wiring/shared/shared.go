@@ -0,0 +1,8 @@
+package shared
+
+import (
+	"github.com/alexandremahdhaoui/di"
+)
+
+// internal cannot be referenced by the ValueFuncs of other packages.
+var internal = di.New("internal")
@@ -0,0 +1,67 @@
+package wiring
+
+type (
+	// +di:provide
+	Handler struct{}
+
+	Sender interface {
+		Notify(to string, messages ...string) error
+		Pending() (int, bool)
+		Close()
+	}
+
+	Pair[K comparable, V any] struct {
+		Key   K
+		Value V
+	}
+
+	Config struct {
+		Addr string
+	}
+
+	DB struct {
+		Config Config
+	}
+
+	// +di:provide:name=Srv,container=App
+	Server struct {
+		// +di:inject
+		DB *DB
+		// +di:inject:name=ConfigValue
+		Config Config
+		Name   string
+	}
+
+	Router struct {
+		Server Server
+		DB     *DB
+	}
+
+	Application struct {
+		DB     *DB
+		Config Config
+	}
+)
+
+// +di:provide
+// +di:injector:provide
+func NewConfig() Config {
+	return Config{Addr: ":8080"}
+}
+
+// +di:provide
+// +di:injector:provide
+func NewDB(cfg Config) (*DB, error) {
+	return &DB{Config: cfg}, nil
+}
+
+// NewRouter depends on a Value of its container and on a Value of the default container.
+// +di:provide:container=App
+func NewRouter(srv Server, db *DB) *Router {
+	return &Router{Server: srv, DB: db}
+}
+
+// +di:injector:name=InitializeApp
+func NewApplication(db *DB, cfg Config) *Application {
+	return &Application{DB: db, Config: cfg}
+}
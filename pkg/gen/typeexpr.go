@@ -0,0 +1,395 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+//nolint:depguard
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"golang.org/x/tools/go/packages"
+	pathpkg "path"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"strconv"
+	"strings"
+)
+
+// typeResolver resolves type expressions, e.g. map[string]*pkg.T or pkg.List[int], against the imports of a package.
+type typeResolver struct {
+	root *loader.Package
+	// loaded caches the packages of TypeImports that are not imported by root.
+	loaded map[string]*types.Package
+}
+
+func newTypeResolver(root *loader.Package) *typeResolver {
+	return &typeResolver{root: root, loaded: make(map[string]*types.Package)}
+}
+
+// resolve parses and resolves the type expression expr.
+//
+// typeImport lists import paths separated by semicolons. Qualified identifiers, e.g. pkg.T, resolve to the packages of
+// typeImport, or to the packages imported by root. Unqualified identifiers resolve to predeclared types, then to the
+// types of the packages of typeImport, then to the types of root.
+func (r *typeResolver) resolve(expr string, typeImport *string) (types.Type, error) {
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse type %q: %w", expr, err)
+	}
+
+	imported := make([]*types.Package, 0)
+
+	if typeImport != nil {
+		for _, path := range strings.Split(*typeImport, ";") {
+			pkg, err := r.lookupPackage(strings.TrimSpace(path))
+			if err != nil {
+				return nil, err
+			}
+
+			imported = append(imported, pkg)
+		}
+	}
+
+	typ, err := r.exprType(parsed, imported)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve type %q: %w", expr, err)
+	}
+
+	return typ, nil
+}
+
+func (r *typeResolver) exprType(expr ast.Expr, imported []*types.Package) (types.Type, error) { //nolint:cyclop,funlen
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return r.exprType(e.X, imported)
+	case *ast.Ident:
+		return r.identType(e.Name, imported)
+	case *ast.SelectorExpr:
+		x, ok := e.X.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("unsupported qualified identifier %s", types.ExprString(e))
+		}
+
+		pkg, err := r.packageByName(x.Name, imported)
+		if err != nil {
+			return nil, err
+		}
+
+		return r.lookupTypeName(pkg, e.Sel.Name)
+	case *ast.StarExpr:
+		elem, err := r.exprType(e.X, imported)
+		if err != nil {
+			return nil, err
+		}
+
+		return types.NewPointer(elem), nil
+	case *ast.ArrayType:
+		elem, err := r.exprType(e.Elt, imported)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Len == nil {
+			return types.NewSlice(elem), nil
+		}
+
+		length, ok := e.Len.(*ast.BasicLit)
+		if !ok || length.Kind != token.INT {
+			return nil, fmt.Errorf("array length %s must be an integer literal", types.ExprString(e.Len))
+		}
+
+		n, ok := constant.Int64Val(constant.MakeFromLiteral(length.Value, token.INT, 0))
+		if !ok {
+			return nil, fmt.Errorf("invalid array length %s", length.Value)
+		}
+
+		return types.NewArray(elem, n), nil
+	case *ast.MapType:
+		key, err := r.exprType(e.Key, imported)
+		if err != nil {
+			return nil, err
+		}
+
+		if !types.Comparable(key) {
+			return nil, fmt.Errorf("invalid map key type %s", key.String())
+		}
+
+		elem, err := r.exprType(e.Value, imported)
+		if err != nil {
+			return nil, err
+		}
+
+		return types.NewMap(key, elem), nil
+	case *ast.ChanType:
+		elem, err := r.exprType(e.Value, imported)
+		if err != nil {
+			return nil, err
+		}
+
+		switch e.Dir {
+		case ast.SEND:
+			return types.NewChan(types.SendOnly, elem), nil
+		case ast.RECV:
+			return types.NewChan(types.RecvOnly, elem), nil
+		default:
+			return types.NewChan(types.SendRecv, elem), nil
+		}
+	case *ast.FuncType:
+		return r.funcType(e, imported)
+	case *ast.InterfaceType:
+		if len(e.Methods.List) > 0 {
+			return nil, fmt.Errorf("unsupported interface literal %s", types.ExprString(e))
+		}
+
+		return types.NewInterfaceType(nil, nil), nil
+	case *ast.IndexExpr:
+		return r.instantiate(e.X, []ast.Expr{e.Index}, imported)
+	case *ast.IndexListExpr:
+		return r.instantiate(e.X, e.Indices, imported)
+	}
+
+	return nil, fmt.Errorf("unsupported type expression %s", types.ExprString(expr))
+}
+
+func (r *typeResolver) identType(name string, imported []*types.Package) (types.Type, error) {
+	// any is an alias of interface{}, which we always render as any.
+	if name == "any" {
+		return types.NewInterfaceType(nil, nil), nil
+	}
+
+	if obj, ok := types.Universe.Lookup(name).(*types.TypeName); ok {
+		return obj.Type(), nil
+	}
+
+	for _, pkg := range imported {
+		if typ, err := r.lookupTypeName(pkg, name); err == nil {
+			return typ, nil
+		}
+	}
+
+	if obj, ok := r.root.Types.Scope().Lookup(name).(*types.TypeName); ok {
+		return obj.Type(), nil
+	}
+
+	return nil, fmt.Errorf("undefined type %s", name)
+}
+
+// instantiate instantiates the generic type x with the type arguments indices.
+func (r *typeResolver) instantiate(x ast.Expr, indices []ast.Expr, imported []*types.Package) (types.Type, error) {
+	generic, err := r.exprType(x, imported)
+	if err != nil {
+		return nil, err
+	}
+
+	named, ok := generic.(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 {
+		return nil, fmt.Errorf("%s is not a generic type", types.TypeString(generic, types.RelativeTo(r.root.Types)))
+	}
+
+	args := make([]types.Type, 0, len(indices))
+
+	for _, index := range indices {
+		arg, err := r.exprType(index, imported)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+	}
+
+	instance, err := types.Instantiate(nil, named, args, true)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return instance, nil
+}
+
+func (r *typeResolver) funcType(e *ast.FuncType, imported []*types.Package) (types.Type, error) {
+	variadic := false
+
+	tuple := func(fields *ast.FieldList) (*types.Tuple, error) {
+		if fields == nil {
+			return types.NewTuple(), nil
+		}
+
+		vars := make([]*types.Var, 0)
+
+		for _, field := range fields.List {
+			fieldType := field.Type
+			if ellipsis, ok := fieldType.(*ast.Ellipsis); ok {
+				fieldType = &ast.ArrayType{Elt: ellipsis.Elt} //nolint:exhaustruct
+				variadic = true
+			}
+
+			typ, err := r.exprType(fieldType, imported)
+			if err != nil {
+				return nil, err
+			}
+
+			for i := 0; i < max(len(field.Names), 1); i++ {
+				vars = append(vars, types.NewParam(token.NoPos, nil, "", typ))
+			}
+		}
+
+		return types.NewTuple(vars...), nil
+	}
+
+	params, err := tuple(e.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := tuple(e.Results)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewSignatureType(nil, nil, nil, params, results, variadic), nil
+}
+
+// packageByName returns the package of the TypeImport named name, or the package imported by root as name.
+func (r *typeResolver) packageByName(name string, imported []*types.Package) (*types.Package, error) {
+	for _, pkg := range imported {
+		if pkg.Name() == name {
+			return pkg, nil
+		}
+	}
+
+	for _, file := range r.root.Syntax {
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+
+			switch {
+			case spec.Name != nil && spec.Name.Name != name:
+				continue
+			case spec.Name == nil && !r.mayBeNamed(path, name):
+				continue
+			}
+
+			pkg, err := r.lookupPackage(path)
+			if err != nil {
+				return nil, err
+			}
+
+			if spec.Name != nil || pkg.Name() == name {
+				return pkg, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("package %s is not imported", name)
+}
+
+// mayBeNamed reports if the package at path may be named name, without loading it. The name of a package that is not
+// loaded is guessed from the last element of its path, e.g.: example.com/go-yaml/v2 -> yaml.
+func (r *typeResolver) mayBeNamed(path, name string) bool {
+	if pkg := r.importedPackage(path); pkg != nil {
+		return pkg.Name() == name
+	}
+
+	base := pathpkg.Base(path)
+	if _, err := strconv.Atoi(strings.TrimPrefix(base, "v")); err == nil && strings.HasPrefix(base, "v") {
+		base = pathpkg.Base(pathpkg.Dir(path))
+	}
+
+	base = strings.TrimPrefix(base, "go-")
+
+	return strings.ReplaceAll(base, "-", "") == name || strings.ReplaceAll(base, "-", "_") == name
+}
+
+// lookupPackage returns the package at path, loading it if it is not imported by root.
+func (r *typeResolver) lookupPackage(path string) (*types.Package, error) {
+	// the unsafe package has no source files to load.
+	if path == types.Unsafe.Path() {
+		return types.Unsafe, nil
+	}
+
+	if pkg := r.importedPackage(path); pkg != nil {
+		return pkg, nil
+	}
+
+	if pkg, ok := r.loaded[path]; ok {
+		return pkg, nil
+	}
+
+	pkgs, err := loader.LoadRoots(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load package %q: %w", path, err)
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("cannot load package %q", path)
+	}
+
+	// the packages that cannot be found are loaded empty, with a list error.
+	for _, err := range pkgs[0].Errors {
+		if err.Kind == packages.ListError {
+			return nil, fmt.Errorf("cannot load package %q: %w", path, err)
+		}
+	}
+
+	// Type errors are ignored, like they are for the roots of the generation: only the declared types are needed.
+	pkgs[0].NeedTypesInfo()
+
+	if pkgs[0].Types == nil {
+		return nil, fmt.Errorf("cannot load package %q", path)
+	}
+
+	r.loaded[path] = pkgs[0].Types
+
+	return pkgs[0].Types, nil
+}
+
+// importedPackage returns the package at path if it is imported by root, directly or not.
+func (r *typeResolver) importedPackage(path string) *types.Package {
+	seen := make(map[*types.Package]bool)
+	queue := []*types.Package{r.root.Types}
+
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		if pkg.Path() == path {
+			return pkg
+		}
+
+		for _, imported := range pkg.Imports() {
+			if !seen[imported] {
+				seen[imported] = true
+				queue = append(queue, imported)
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookupTypeName returns the type named name in pkg. Unexported types can only be used in root.
+func (r *typeResolver) lookupTypeName(pkg *types.Package, name string) (types.Type, error) {
+	obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok || (!obj.Exported() && pkg != r.root.Types) {
+		return nil, fmt.Errorf("undefined type %s.%s", pkg.Name(), name)
+	}
+
+	return obj.Type(), nil
+}
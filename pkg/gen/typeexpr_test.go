@@ -0,0 +1,81 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen_test
+
+import (
+	"github.com/alexandremahdhaoui/di/pkg/gen"
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+)
+
+const typeExprPkg = "github.com/alexandremahdhaoui/di/pkg/gen/testdata/typeexpr"
+
+var _ = Describe("Type expressions", func() {
+	DescribeTable("should resolve the type expressions of the ValueFunc markers",
+		func(expr string, typeImport *string, expected string) {
+			typ, err := gen.ResolveType("./testdata/typeexpr", expr, typeImport)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(typ).To(Equal(expected))
+		},
+		Entry("a type of the package", "Pair[string, *Plain]", nil,
+			typeExprPkg+".Pair[string, *"+typeExprPkg+".Plain]"),
+		Entry("an unexported type of the package", "[]local", nil, "[]"+typeExprPkg+".local"),
+		Entry("a package imported by the package", "*ast.File", nil, "*"+typeExprPkg+"/ast.File"),
+		Entry("the unqualified types of a typeImport", "[]*File", ptr("go/ast"), "[]*go/ast.File"),
+		// go/token and go/ast both declare a File type.
+		Entry("the unqualified types of the first typeImport declaring them", "map[Pos]File",
+			ptr("go/token;go/ast"), "map[go/token.Pos]go/token.File"),
+		Entry("the qualified types of several typeImports", "map[token.Pos]*ast.File", ptr("go/ast; go/token"),
+			"map[go/token.Pos]*go/ast.File"),
+		// the typeImports take precedence over the imports of the package, which is also named ast.
+		Entry("a typeImport named like an import of the package", "*ast.File", ptr("go/ast"), "*go/ast.File"),
+	)
+
+	DescribeTable("should generate the code of the resolved types",
+		func(expr string, typeImport *string, expected string) {
+			code, err := gen.TypeCode("./testdata/typeexpr", expr, typeImport)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(code).To(Equal("var v " + expected))
+		},
+		Entry("a basic type", "[]byte", nil, "[]byte"),
+		Entry("unsafe.Pointer", "map[string]unsafe.Pointer", ptr("unsafe"), "map[string]unsafe.Pointer"),
+		Entry("an instantiated generic type", "Pair[string, *ast.File]", nil,
+			"typeexpr.Pair[string, *ast.File]"),
+		Entry("a channel", "<-chan [2]any", nil, "<-chan [2]any"),
+		Entry("a variadic function", "func(string, ...int) (bool, error)", nil, "func(string, ...int) (bool, error)"),
+	)
+
+	DescribeTable("should report the type expressions that cannot be resolved",
+		func(expr string, typeImport *string, expected string) {
+			_, err := gen.ResolveType("./testdata/typeexpr", expr, typeImport)
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+		},
+		Entry("an undefined identifier", "[]Missing", nil, "undefined type Missing"),
+		Entry("an instantiated type that is not generic", "Plain[int]", nil, "Plain is not a generic type"),
+		Entry("a generic type with missing type arguments", "Pair[string]", nil, "got 1 type arguments but have 2 type parameters"),
+		Entry("an unexported type of another package", "*ast.node", nil, "undefined type ast.node"),
+		Entry("a package that is not imported", "*format.Node", nil, "package format is not imported"),
+		Entry("a typeImport that cannot be loaded", "File", ptr(typeExprPkg+"/missing"),
+			`cannot load package "`+typeExprPkg+`/missing"`),
+		Entry("an identifier declared by none of the typeImports", "map[Pos]*Missing", ptr("go/token; go/ast"),
+			"undefined type Missing"),
+	)
+})
+
+func ptr(s string) *string {
+	return &s
+}
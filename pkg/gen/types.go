@@ -0,0 +1,196 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+import (
+	"fmt"
+	"github.com/dave/jennifer/jen"
+	"go/types"
+)
+
+// typeCode converts a types.Type into jen code, qualifying named types with their package path.
+func typeCode(typ types.Type) (*jen.Statement, error) { //nolint:cyclop,funlen
+	switch t := typ.(type) {
+	case *types.Basic:
+		switch t.Kind() { //nolint:exhaustive
+		case types.Invalid:
+			return nil, fmt.Errorf("invalid type")
+		case types.UnsafePointer:
+			// the name of unsafe.Pointer is not qualified.
+			return jen.Qual("unsafe", "Pointer"), nil
+		}
+
+		return jen.Id(t.Name()), nil
+	case *types.Named:
+		obj := t.Obj()
+
+		var stt *jen.Statement
+		if obj.Pkg() == nil {
+			// predeclared named types, e.g. error or comparable
+			stt = jen.Id(obj.Name())
+		} else {
+			stt = jen.Qual(obj.Pkg().Path(), obj.Name())
+		}
+
+		if t.TypeArgs().Len() == 0 {
+			return stt, nil
+		}
+
+		args := make([]jen.Code, 0, t.TypeArgs().Len())
+
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			arg, err := typeCode(t.TypeArgs().At(i))
+			if err != nil {
+				return nil, err
+			}
+
+			args = append(args, arg)
+		}
+
+		return stt.Types(args...), nil
+	case *types.TypeParam:
+		return jen.Id(t.Obj().Name()), nil
+	case *types.Pointer:
+		elem, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return jen.Op("*").Add(elem), nil
+	case *types.Slice:
+		elem, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return jen.Index().Add(elem), nil
+	case *types.Array:
+		elem, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return jen.Index(jen.Lit(int(t.Len()))).Add(elem), nil
+	case *types.Map:
+		key, err := typeCode(t.Key())
+		if err != nil {
+			return nil, err
+		}
+
+		elem, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return jen.Map(key).Add(elem), nil
+	case *types.Chan:
+		elem, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		switch t.Dir() {
+		case types.SendOnly:
+			return jen.Chan().Op("<-").Add(elem), nil
+		case types.RecvOnly:
+			return jen.Op("<-").Chan().Add(elem), nil
+		default:
+			return jen.Chan().Add(elem), nil
+		}
+	case *types.Signature:
+		params, err := tupleCode(t.Params(), t.Variadic())
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := tupleCode(t.Results(), false)
+		if err != nil {
+			return nil, err
+		}
+
+		stt := jen.Func().Params(params...)
+
+		switch len(results) {
+		case 0:
+			return stt, nil
+		case 1:
+			return stt.Add(results[0]), nil
+		default:
+			return stt.Parens(jen.List(results...)), nil
+		}
+	case *types.Interface:
+		if t.Empty() {
+			return jen.Any(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", typ.String())
+}
+
+func tupleCode(tuple *types.Tuple, variadic bool) ([]jen.Code, error) {
+	sl := make([]jen.Code, 0, tuple.Len())
+
+	for i := 0; i < tuple.Len(); i++ {
+		typ := tuple.At(i).Type()
+
+		if variadic && i == tuple.Len()-1 {
+			elem, err := typeCode(typ.(*types.Slice).Elem()) //nolint:forcetypeassert
+			if err != nil {
+				return nil, err
+			}
+
+			sl = append(sl, jen.Op("...").Add(elem))
+
+			continue
+		}
+
+		stt, err := typeCode(typ)
+		if err != nil {
+			return nil, err
+		}
+
+		sl = append(sl, stt)
+	}
+
+	return sl, nil
+}
+
+// zeroValueCode returns the jen code of the zero value of typ.
+func zeroValueCode(typ types.Type) (*jen.Statement, error) {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return jen.False(), nil
+		case t.Info()&types.IsString != 0:
+			return jen.Lit(""), nil
+		case t.Info()&types.IsNumeric != 0:
+			return jen.Lit(0), nil
+		default:
+			return jen.Nil(), nil
+		}
+	case *types.Struct, *types.Array:
+		stt, err := typeCode(typ)
+		if err != nil {
+			return nil, err
+		}
+
+		return stt.Values(), nil
+	default:
+		return jen.Nil(), nil
+	}
+}
@@ -19,20 +19,34 @@ package gen
 import (
 	"bytes"
 	"fmt"
+	"go/ast"
+	"go/build/constraint"
 	"go/format"
+	"go/token"
+	"golang.org/x/mod/modfile"
 	"io"
+	"os"
+	"path/filepath"
 	"sigs.k8s.io/controller-tools/pkg/genall"
 	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sigs.k8s.io/controller-tools/pkg/version"
+	"slices"
+	"sort"
 	"strings"
+	"text/template"
 	"unicode"
 )
 
+// defaultHeader is the header template of the generated files when no header file is specified. See HeaderData.
+const defaultHeader = "// This file is generated from the +di markers of the package {{ .Package }}.\n"
+
 const header = `
-//go:build !ignore_autogenerated
+//go:build %[3]s
 
 %[2]s
 
-// Code generated by di-gen. DO NOT EDIT.
+// Code generated by di-gen %[4]s. DO NOT EDIT.
 `
 
 func markerName(prefix, name string) string {
@@ -45,38 +59,289 @@ func title(s string) string {
 	return string(append([]rune{unicode.ToUpper(r[0])}, r[1:]...))
 }
 
+// funcMarkers parses the markers found in the doc comment of a func declaration.
+// The markers.Collector does not associate markers with funcs, thus we look them up as type-level markers.
+func funcMarkers(reg *markers.Registry, decl *ast.FuncDecl) (markers.MarkerValues, error) {
+	values := make(markers.MarkerValues)
+
+	if decl.Doc == nil {
+		return values, nil
+	}
+
+	for _, comment := range decl.Doc.List {
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+		if !strings.HasPrefix(text, "+") {
+			continue
+		}
+
+		def := reg.Lookup(text, markers.DescribesType)
+		if def == nil {
+			continue
+		}
+
+		value, err := def.Parse(text)
+		if err != nil {
+			return nil, loader.ErrFromNode(err, comment)
+		}
+
+		values[def.Name] = append(values[def.Name], value)
+	}
+
+	return values, nil
+}
+
+// packageMarker is a package-level marker, with its comment, position and doc comment.
+type packageMarker struct {
+	value   interface{}
+	comment *ast.Comment
+	// position is relative to the root of the module.
+	position Position
+	// doc is made of the non-marker lines preceding the marker in its comment group.
+	doc string
+}
+
+// packageMarkers returns the registered package-level markers of root in the order of the sources, and the errors of
+// the markers that cannot be parsed. markers.PackageMarkers does not keep the positions of the markers, thus we parse
+// the comments of the files.
+func packageMarkers(reg *markers.Registry, root *loader.Package) ([]packageMarker, []error) {
+	moduleDir := moduleDir(root)
+	pkgMarkers := make([]packageMarker, 0)
+	errs := make([]error, 0)
+
+	for _, file := range root.Syntax {
+		for _, group := range file.Comments {
+			doc := make([]string, 0)
+
+			for _, comment := range group.List {
+				text := markerText(comment)
+
+				switch {
+				case !strings.HasPrefix(text, "+"):
+					if text != "" {
+						doc = append(doc, text)
+					}
+
+					continue
+				case reg.Lookup(text, markers.DescribesPackage) == nil:
+					doc = doc[:0]
+
+					continue
+				}
+
+				value, err := reg.Lookup(text, markers.DescribesPackage).Parse(text)
+				if err != nil {
+					errs = append(errs, loader.ErrFromNode(err, comment))
+				} else {
+					pkgMarkers = append(pkgMarkers, packageMarker{
+						value:    value,
+						comment:  comment,
+						position: sourcePosition(root, comment.Pos(), moduleDir),
+						doc:      strings.Join(doc, " "),
+					})
+				}
+
+				doc = doc[:0]
+			}
+		}
+	}
+
+	return pkgMarkers, errs
+}
+
+// addErrors adds errs to root, skipping the errors already added by another generator.
+func addErrors(root *loader.Package, errs ...error) {
+	added := make(map[string]bool, len(root.Errors))
+	for _, err := range root.Errors {
+		added[err.Error()] = true
+	}
+
+	for _, err := range errs {
+		// the errors are converted to packages.Errors by AddError, thus they are compared once converted.
+		previous := len(root.Errors)
+		root.AddError(err)
+
+		converted := slices.Clone(root.Errors[previous:])
+		root.Errors = root.Errors[:previous]
+
+		for _, convertedErr := range converted {
+			if !added[convertedErr.Error()] {
+				added[convertedErr.Error()] = true
+
+				root.Errors = append(root.Errors, convertedErr)
+			}
+		}
+	}
+}
+
+// markerText returns the text of a line comment.
+func markerText(comment *ast.Comment) string {
+	return strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+}
+
+// sourcePosition returns the Position of pos, relative to moduleDir if it is not empty.
+func sourcePosition(root *loader.Package, pos token.Pos, moduleDir string) Position {
+	position := root.Fset.Position(pos)
+	file := position.Filename
+
+	if moduleDir != "" {
+		if rel, err := filepath.Rel(moduleDir, file); err == nil {
+			file = filepath.ToSlash(rel)
+		}
+	}
+
+	return Position{File: file, Line: position.Line}
+}
+
+// sortMarkerValues sorts the values of a marker by the key returned by keyFunc, so that the output of the generators is
+// stable.
+func sortMarkerValues(values []interface{}, keyFunc func(value interface{}) string) {
+	sort.SliceStable(values, func(i, j int) bool {
+		return keyFunc(values[i]) < keyFunc(values[j])
+	})
+}
+
 func generatedFilename(prefix, name string) string {
 	return fmt.Sprintf("zz_generated.%s.%s.go", prefix, name)
 }
 
+// outputFilename returns filename, or the default filename of the generator name if filename is empty.
+func outputFilename(filename, name string) string {
+	if filename == "" {
+		return generatedFilename(DIMarkerName, name)
+	}
+
+	return filename
+}
+
+// splitFilename returns the filename of the part of the file filename generated for the container, e.g.:
+// zz_generated.di.valuefunc.App.go.
+func splitFilename(filename, container string) string {
+	return strings.TrimSuffix(filename, ".go") + "." + container + ".go"
+}
+
+// buildConstraint returns the build constraint of the generated files, requiring buildTag if it is not empty.
+func buildConstraint(buildTag string) (string, error) {
+	if buildTag == "" {
+		return "!ignore_autogenerated", nil
+	}
+
+	expr, err := constraint.Parse("//go:build " + buildTag)
+	if err != nil {
+		return "", fmt.Errorf("invalid build tag %q: %w", buildTag, err)
+	}
+
+	if _, ok := expr.(*constraint.OrExpr); ok {
+		return "!ignore_autogenerated && (" + expr.String() + ")", nil
+	}
+
+	return "!ignore_autogenerated && " + expr.String(), nil
+}
+
 type generateFileOptions struct {
-	buffer                           *bytes.Buffer
-	ctx                              *genall.GenerationContext
-	filename, headerFile, headerYear string
-	root                             *loader.Package
+	buffer *bytes.Buffer
+	ctx    *genall.GenerationContext
+	// generator is the name of the generator of the file, written in its header.
+	generator                            string
+	filename, buildTag, headerFile, year string
+	root                                 *loader.Package
 }
 
-func generateFile(o generateFileOptions) error {
-	var headerText string
+// HeaderData is the data available to the header templates, e.g.: "Copyright {{ .Year }}".
+type HeaderData struct {
+	// Year is the Year option of the generator.
+	Year string
+	// Module is the path of the module of the generated package.
+	Module string
+	// Package is the import path of the generated package.
+	Package string
+	// Version is the version of di-gen.
+	Version string
+}
+
+// headerText returns the header of the generated files of o.root, executing the template of the header file, or the
+// defaultHeader template if no header file is specified.
+func headerText(o generateFileOptions) (string, error) {
+	name, text := "default header", defaultHeader
 
 	if o.headerFile != "" {
 		headerBytes, err := o.ctx.ReadFile(o.headerFile)
 		if err != nil {
-			return err
+			return "", err //nolint:wrapcheck
 		}
 
-		headerText = string(headerBytes)
+		// " YEAR" is substituted for compatibility with the header files of controller-gen.
+		name, text = "header file "+o.headerFile, strings.ReplaceAll(string(headerBytes), " YEAR", " "+o.year)
 	}
 
-	headerText = strings.ReplaceAll(headerText, " YEAR", " "+o.headerYear)
-	if headerText == "" {
-		panic("at the disco!")
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse %s: %w", name, err)
 	}
 
 	buffer := new(bytes.Buffer)
+	if err := tmpl.Execute(buffer, HeaderData{
+		Year:    o.year,
+		Module:  modulePath(o.root),
+		Package: o.root.PkgPath,
+		Version: version.Version(),
+	}); err != nil {
+		return "", fmt.Errorf("cannot execute %s: %w", name, err)
+	}
+
+	return buffer.String(), nil
+}
+
+// modulePath returns the path of the module of root, read from the closest go.mod, or an empty string if it is not
+// found.
+func modulePath(root *loader.Package) string {
+	dir := moduleDir(root)
+	if dir == "" {
+		return ""
+	}
 
-	_, err := fmt.Fprintf(buffer, header, o.root.Name, headerText)
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
 	if err != nil {
+		return ""
+	}
+
+	return modfile.ModulePath(data)
+}
+
+// moduleDir returns the directory of the closest go.mod of root, or an empty string if it is not found.
+func moduleDir(root *loader.Package) string {
+	if len(root.CompiledGoFiles) == 0 {
+		return ""
+	}
+
+	for dir := filepath.Dir(root.CompiledGoFiles[0]); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+func generateFile(o generateFileOptions) error {
+	if filepath.Base(o.filename) != o.filename || filepath.Ext(o.filename) != ".go" {
+		return fmt.Errorf("invalid filename %q, expected the name of a Go file", o.filename)
+	}
+
+	text, err := headerText(o)
+	if err != nil {
+		return err
+	}
+
+	goBuild, err := buildConstraint(o.buildTag)
+	if err != nil {
+		return err
+	}
+
+	buffer := new(bytes.Buffer)
+
+	if _, err := fmt.Fprintf(buffer, header, o.root.Name, text, goBuild, o.generator); err != nil {
 		return err //nolint:wrapcheck
 	}
 
@@ -0,0 +1,291 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+//nolint:depguard
+import (
+	"fmt"
+	"github.com/alexandremahdhaoui/di/pkg/astutil"
+	"github.com/alexandremahdhaoui/di/pkg/diutil"
+	"go/ast"
+	"go/types"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// markerValidator validates the container and valuefunc markers of a package.
+type markerValidator struct {
+	reg       *markers.Registry
+	root      *loader.Package
+	moduleDir string
+	// containers maps the identifiers of the containers declared by markers or di.New vars to their position.
+	containers map[string]Position
+	// identifiers maps the identifiers generated for the markers to the position of their marker.
+	identifiers map[string]Position
+	// values maps the keys of the Values of each container to the position of their marker.
+	values map[string]Position
+	// foreign caches the exported containers of the other packages, by import path.
+	foreign map[string]map[string]Position
+	errs    []error
+}
+
+// validateMarkers validates the container and valuefunc markers of root, and returns positioned errors:
+//
+//   - The Container of a ValueFunc must be declared in root, by a container marker or by a di.New var. A Container
+//     qualified with the import path of another package must be exported by this package.
+//   - The names of the ValueFuncs of a Container must be unique.
+//   - The identifiers generated for the markers must be unique, and must not collide with the identifiers declared in
+//     root.
+//
+// The markers that cannot be parsed are not reported, they are reported by the generators.
+func validateMarkers(reg *markers.Registry, root *loader.Package) []error {
+	v := &markerValidator{
+		reg:         reg,
+		root:        root,
+		moduleDir:   moduleDir(root),
+		containers:  newContainerDecls(root),
+		identifiers: make(map[string]Position),
+		values:      make(map[string]Position),
+		foreign:     make(map[string]map[string]Position),
+		errs:        make([]error, 0),
+	}
+
+	pkgMarkers, _ := packageMarkers(reg, root)
+
+	// containers are validated first, as ValueFuncs may be declared before their Container.
+	for _, m := range pkgMarkers {
+		if container, ok := m.value.(Container); ok {
+			v.container(m, container)
+		}
+	}
+
+	for _, m := range pkgMarkers {
+		if valueFunc, ok := m.value.(ValueFunc); ok {
+			v.valueFunc(m, valueFunc)
+		}
+	}
+
+	return v.errs
+}
+
+func (v *markerValidator) container(m packageMarker, container Container) {
+	name := container.nameWithExportedCasing()
+
+	if position, ok := v.containers[name]; ok {
+		v.errorf(m, "container %s is already declared at %s", name, position)
+
+		return
+	}
+
+	v.containers[name] = m.position
+
+	identifiers := []string{name}
+	if container.isTyped() {
+		identifiers = append(identifiers, container.typedName())
+	}
+
+	v.claim(m, "container "+name, identifiers...)
+}
+
+func (v *markerValidator) valueFunc(m packageMarker, valueFunc ValueFunc) {
+	path, ident := valueFunc.containerRef()
+	container := path + "." + ident
+
+	switch {
+	case valueFunc.Container == nil:
+	case path == "" || path == v.root.PkgPath:
+		container = v.root.PkgPath + "." + ident
+
+		if _, ok := v.containers[ident]; !ok {
+			v.errorf(m, "valuefunc %s: container %s is not declared in package %s, declare it with the container "+
+				"marker or with di.New", valueFunc.Name, ident, v.root.PkgPath)
+
+			return
+		}
+	default:
+		if err := v.foreignContainer(path, ident); err != nil {
+			v.errorf(m, "valuefunc %s: %s", valueFunc.Name, err)
+
+			return
+		}
+	}
+
+	key := container + "." + valueFunc.key()
+	if position, ok := v.values[key]; ok {
+		v.errorf(m, "valuefunc %s: Value %s is already declared in container %s at %s", valueFunc.Name,
+			valueFunc.key(), ident, position)
+
+		return
+	}
+
+	v.values[key] = m.position
+
+	funcName := valueFunc.funcName()
+	identifiers := []string{funcName}
+
+	if valueFunc.hasSetters() {
+		identifiers = append(identifiers, "Set"+funcName, "MustSet"+funcName, "Init"+funcName)
+	}
+
+	if valueFunc.hasFake() {
+		identifiers = append(identifiers, "Fake"+funcName, "InstallFake"+funcName)
+	}
+
+	v.claim(m, "valuefunc "+valueFunc.Name, identifiers...)
+}
+
+// claim reserves the identifiers generated for the marker m, described by owner.
+func (v *markerValidator) claim(m packageMarker, owner string, identifiers ...string) {
+	for _, ident := range identifiers {
+		if position, ok := v.identifiers[ident]; ok {
+			v.errorf(m, "%s: identifier %s is already generated for the marker at %s", owner, ident, position)
+
+			continue
+		}
+
+		v.identifiers[ident] = m.position
+
+		if obj := v.root.Types.Scope().Lookup(ident); obj != nil {
+			v.errorf(m, "%s: identifier %s collides with the %s declared at %s", owner, ident, objectKind(obj),
+				sourcePosition(v.root, obj.Pos(), v.moduleDir))
+		}
+	}
+}
+
+// entrypointIdentifiers are the identifiers generated by the EntrypointGenerator.
+var entrypointIdentifiers = []string{"BuildContainers", "ValidateContainers"} //nolint:gochecknoglobals
+
+// validateEntrypoint returns positioned errors for the declarations of root colliding with the identifiers generated
+// by the EntrypointGenerator.
+func validateEntrypoint(root *loader.Package) []error {
+	errs := make([]error, 0)
+
+	for ident, obj := range root.TypesInfo.Defs {
+		if obj == nil || root.Types.Scope().Lookup(ident.Name) != obj {
+			continue
+		}
+
+		for _, generated := range entrypointIdentifiers {
+			if ident.Name == generated {
+				errs = append(errs, loader.ErrFromNode(fmt.Errorf("entrypoint: identifier %s collides with the %s "+
+					"declared here", generated, objectKind(obj)), ident))
+			}
+		}
+	}
+
+	return errs
+}
+
+// foreignContainer checks that the package path exports the container ident.
+func (v *markerValidator) foreignContainer(path, ident string) error {
+	containers, ok := v.foreign[path]
+	if !ok {
+		pkgs, err := loader.LoadRoots(path)
+		if err != nil || len(pkgs) != 1 || len(pkgs[0].CompiledGoFiles) == 0 {
+			return fmt.Errorf("cannot load the package %q of container %s", path, ident)
+		}
+
+		pkg := pkgs[0]
+		pkg.NeedTypesInfo()
+
+		containers = newContainerDecls(pkg)
+		pkgMarkers, _ := packageMarkers(v.reg, pkg)
+
+		for _, m := range pkgMarkers {
+			if container, ok := m.value.(Container); ok {
+				containers[container.nameWithExportedCasing()] = m.position
+			}
+		}
+
+		v.foreign[path] = containers
+	}
+
+	if _, ok := containers[ident]; !ok {
+		return fmt.Errorf("container %s is not declared in package %s", ident, path)
+	}
+
+	if !ast.IsExported(ident) {
+		return fmt.Errorf("container %s of package %s is not exported", ident, path)
+	}
+
+	return nil
+}
+
+func (v *markerValidator) errorf(m packageMarker, format string, args ...interface{}) {
+	v.errs = append(v.errs, loader.ErrFromNode(fmt.Errorf(format, args...), m.comment))
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// newContainerDecls returns the positions of the containers declared by the di.New vars of root, by identifier.
+func newContainerDecls(root *loader.Package) map[string]Position {
+	moduleDir := moduleDir(root)
+	containers := make(map[string]Position)
+
+	for _, file := range root.Syntax {
+		diPkgIdent, ok := diImportIdent(file)
+		if !ok {
+			continue
+		}
+
+		decls := astutil.ContainerDeclFromNode(file, astutil.Meta{ //nolint:exhaustruct
+			Pkg:      root.Name,
+			Filepath: root.Fset.Position(file.Pos()).Filename,
+			Module:   root.PkgPath,
+		}, diPkgIdent)
+
+		for _, decl := range decls {
+			position := sourcePosition(root, file.Pos(), moduleDir)
+			if obj := root.Types.Scope().Lookup(decl.Ident.String()); obj != nil {
+				position = sourcePosition(root, obj.Pos(), moduleDir)
+			}
+
+			containers[decl.Ident.String()] = position
+		}
+	}
+
+	return containers
+}
+
+// diImportIdent returns the identifier of the di package in file, if it is imported.
+func diImportIdent(file *ast.File) (astutil.Ident, bool) {
+	for _, spec := range file.Imports {
+		if pkgImport := astutil.NewPkgImport(spec); pkgImport.Pkg() == diutil.PkgPath {
+			return pkgImport.Ident(), true
+		}
+	}
+
+	return "", false
+}
+
+// objectKind returns the kind of the declaration of obj, e.g.: "func".
+func objectKind(obj types.Object) string {
+	switch obj.(type) {
+	case *types.Func:
+		return "func"
+	case *types.Var:
+		return "var"
+	case *types.Const:
+		return "const"
+	case *types.TypeName:
+		return "type"
+	default:
+		return "identifier"
+	}
+}
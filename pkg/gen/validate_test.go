@@ -0,0 +1,99 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen_test
+
+import (
+	"github.com/alexandremahdhaoui/di/pkg/gen"
+	. "github.com/onsi/ginkgo/v2" //nolint:depguard
+	. "github.com/onsi/gomega"    //nolint:depguard
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+var _ = Describe("Marker validation", func() {
+	DescribeTable("should report positioned errors instead of generating files",
+		func(generator genall.Generator) {
+			files, errs := run(generator, "./testdata/invalid")
+			Expect(files).To(BeEmpty())
+
+			messages := make([]string, 0, len(errs))
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+
+			Expect(messages).To(ConsistOf(
+				And(ContainSubstring("doc.go:2:"),
+					ContainSubstring("container app is already declared at pkg/gen/testdata/invalid/doc.go:1")),
+				And(ContainSubstring("doc.go:3:"),
+					ContainSubstring("container Registry is already declared at pkg/gen/testdata/invalid/invalid.go:12")),
+				And(ContainSubstring("doc.go:4:"),
+					ContainSubstring("valuefunc port: container Missing is not declared")),
+				And(ContainSubstring("doc.go:6:"),
+					ContainSubstring("valuefunc host: Value Host is already declared in container app at "+
+						"pkg/gen/testdata/invalid/doc.go:5")),
+				And(ContainSubstring("doc.go:7:"),
+					ContainSubstring("valuefunc host: identifier Host is already generated for the marker at "+
+						"pkg/gen/testdata/invalid/doc.go:5")),
+				And(ContainSubstring("doc.go:8:"),
+					ContainSubstring("valuefunc config: identifier Config collides with the type declared at "+
+						"pkg/gen/testdata/invalid/invalid.go:16")),
+				And(ContainSubstring("doc.go:9:"),
+					ContainSubstring("valuefunc timeout: identifier SetTimeout collides with the func declared at "+
+						"pkg/gen/testdata/invalid/invalid.go:19")),
+				And(ContainSubstring("doc.go:10:"),
+					ContainSubstring("valuefunc region: container Missing is not declared in package "+
+						"github.com/alexandremahdhaoui/di/pkg/gen/testdata/wiring/shared")),
+				And(ContainSubstring("doc.go:11:"),
+					ContainSubstring("valuefunc zone: container internal of package "+
+						"github.com/alexandremahdhaoui/di/pkg/gen/testdata/wiring/shared is not exported")),
+			))
+		},
+		Entry("container", gen.ContainerGenerator{}),
+		Entry("valuefunc", gen.ValueFuncGenerator{}),
+		Entry("fake", gen.FakeGenerator{}),
+	)
+
+	It("should report the errors once when several generators validate the markers", func() {
+		_, single := run(gen.ContainerGenerator{}, "./testdata/invalid")
+		_, errs := runAll("./testdata/invalid",
+			gen.ContainerGenerator{}, gen.ValueFuncGenerator{}, gen.EntrypointGenerator{}, gen.FakeGenerator{})
+
+		Expect(errs).To(HaveLen(len(single)))
+	})
+
+	It("should ignore the provide markers and reject the fields of the injector provide markers", func() {
+		files, errs := run(gen.InjectorGenerator{}, "./testdata/injector")
+		Expect(files).To(BeEmpty())
+
+		messages := make([]string, 0, len(errs))
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+
+		Expect(messages).To(ConsistOf(
+			And(ContainSubstring("injector.go:18:"), ContainSubstring(`extra arguments provided: "Database"`)),
+			And(ContainSubstring("injector.go:24:"),
+				ContainSubstring("no provider found for Config, required by NewApp")),
+		))
+	})
+
+	It("should report the declarations colliding with the entrypoint", func() {
+		files, errs := run(gen.EntrypointGenerator{}, "./testdata/entrypoint")
+		Expect(files).To(BeEmpty())
+		Expect(errs).To(ConsistOf(MatchError(And(ContainSubstring("entrypoint.go:11:"),
+			ContainSubstring("entrypoint: identifier BuildContainers collides with the func declared here")))))
+	})
+})
@@ -18,11 +18,15 @@ package gen
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/alexandremahdhaoui/di/pkg/diutil"
 	"github.com/dave/jennifer/jen"
+	pathpkg "path"
 	//nolint:depguard
 	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers" //nolint:depguard
+	"strings"
 )
 
 //go:generate go run sigs.k8s.io/controller-tools/cmd/helpgen generate:headerFile=../../hack/boilerplate.go.txt,year=2023
@@ -33,7 +37,8 @@ type ValueFunc struct {
 	// Name identifies the func that will be used to access the defined value
 	Name string
 	// Container (optional string) specifies the di.Container's Name that will be used to store the Value.
-	//    - Container should always resolve to a di.Container defined in the current pkg.
+	//    - Container resolves to a di.Container defined in the current pkg, or in another pkg when it is qualified
+	//      with the import path of the pkg, e.g.: example.com/app/wiring.App.
 	//    - In other words, the "consumer" of a di.Value, defines both the di.Value and the di.Container in the same
 	//      package where the di.Value is consumed.
 	//      It's the job of the "producer" of the injectable value to import the ValueFunc from the getter package.
@@ -42,13 +47,22 @@ type ValueFunc struct {
 	//      by the "consumers" and the "producers" (!! Concurrent producers should NEVER be allowed: greatly reduce the
 	//      side effects)
 	Container *string
-	// Type defines the `type` T to the Value[T].
+	// Type defines the `type` T to the Value[T]. Type accepts any Go type expression, e.g.: *pkg.T, []pkg.T,
+	// map[string]*pkg.T or pkg.List[int]. Type expressions containing commas must be quoted.
 	Type string
-	// TypeImport defines package import for the specific type.
+	// TypeImport defines package import for the specific type. Unqualified identifiers of Type resolve to the types
+	// of this package. Several import paths can be separated by semicolons and quoted, e.g.:
+	// typeImport="go/ast;go/token", for types qualified with packages that are not imported by the current pkg.
 	TypeImport *string
 	// Exported indicates if the ValueFunc should be exported or not.
 	// Container is exported by default.
 	Exported *bool
+	// Setters indicates if the SetName, MustSetName and InitName funcs should be generated.
+	// Setters are not generated by default.
+	Setters *bool
+	// Fake indicates if the FakeGenerator should generate a fake implementation of the interface type T.
+	// Fakes are not generated by default.
+	Fake *bool
 }
 
 func (vf *ValueFunc) nameWithExportedCasing() string {
@@ -67,6 +81,91 @@ func (vf *ValueFunc) isExported() bool {
 	return *vf.Exported
 }
 
+func (vf *ValueFunc) hasFake() bool {
+	if vf.Fake == nil {
+		return false
+	}
+
+	return *vf.Fake
+}
+
+func (vf *ValueFunc) hasSetters() bool {
+	if vf.Setters == nil {
+		return false
+	}
+
+	return *vf.Setters
+}
+
+// funcName returns the identifier of the generated func.
+func (vf *ValueFunc) funcName() string {
+	return title(vf.nameWithExportedCasing())
+}
+
+// key returns the key identifying the Value in its di.Container.
+func (vf *ValueFunc) key() string {
+	return vf.nameWithExportedCasing()
+}
+
+// typeCode returns the code of the type T of the Value[T].
+func (vf *ValueFunc) typeCode(r *typeResolver) (*jen.Statement, error) {
+	typ, err := r.resolve(vf.Type, vf.TypeImport)
+	if err != nil {
+		return nil, fmt.Errorf("valuefunc %s: %w", vf.Name, err)
+	}
+
+	stt, err := typeCode(typ)
+	if err != nil {
+		return nil, fmt.Errorf("valuefunc %s: %w", vf.Name, err)
+	}
+
+	return stt, nil
+}
+
+// containerRef returns the import path and the identifier of the di.Container holding the Value. The import path is
+// empty if the Container is not qualified, i.e. if it is defined in the current package.
+func (vf *ValueFunc) containerRef() (string, string) {
+	if vf.Container == nil {
+		return diutil.PkgPath, "DefaultContainer"
+	}
+
+	// identifiers cannot contain dots, thus the last dot separates the import path from the identifier.
+	if i := strings.LastIndex(*vf.Container, "."); i >= 0 {
+		return (*vf.Container)[:i], (*vf.Container)[i+1:]
+	}
+
+	return "", *vf.Container
+}
+
+// inContainer reports if the Value is held by the di.Container ident of the package pkgPath.
+func (vf *ValueFunc) inContainer(pkgPath, ident string) bool {
+	path, name := vf.containerRef()
+
+	return vf.Container != nil && (path == "" || path == pkgPath) && name == ident
+}
+
+// containerName returns the name of the di.Container holding the Value, relative to the package pkgPath, e.g.:
+// DefaultContainer, App or shared.Shared.
+func (vf *ValueFunc) containerName(pkgPath string) string {
+	path, ident := vf.containerRef()
+	if path == "" || path == pkgPath || path == diutil.PkgPath {
+		return ident
+	}
+
+	return pathpkg.Base(path) + "." + ident
+}
+
+// containerCode returns the code referencing the di.Container holding the Value.
+func (vf *ValueFunc) containerCode() *jen.Statement {
+	path, ident := vf.containerRef()
+	if path == "" {
+		return jen.Id(ident)
+	}
+
+	// jen renders the identifiers of the package of the file unqualified.
+	return jen.Qual(path, ident)
+}
+
 var ValueFuncMarkerDefinition = markers.Must( //nolint:gochecknoglobals
 	markers.MakeDefinition(markerName(DIMarkerName, ValueFuncMarkerName), markers.DescribesPackage, ValueFunc{}), //nolint:lll,exhaustruct,exhaustivestruct
 )
@@ -81,7 +180,9 @@ var ValueFuncMarkerDefinition = markers.Must( //nolint:gochecknoglobals
 //   - Name (string) identifies the func that will be used to access the defined value.
 //
 //   - Container (optional string) specifies the di.Container's Name that will be used to store the Value.
-//     Container should always resolve to a di.Container defined in the current pkg.
+//     Container resolves to a di.Container defined in the current pkg, or in another pkg when it is qualified with
+//     the import path of the pkg, e.g.: container=example.com/app/wiring.App. The di.Container of another pkg must
+//     be exported.
 //     In other words, the "consumer" of a di.Value, defines both the di.Value and the di.Container in the same
 //     package where the di.Value is consumed.
 //     It's the job of the "producer" of the injectable value to import the ValueFunc from the getter package.
@@ -91,21 +192,52 @@ var ValueFuncMarkerDefinition = markers.Must( //nolint:gochecknoglobals
 //     side effects)
 //
 //   - Type (string) defines the type T to the Value[T].
+//     Type accepts any Go type expression, e.g.: *pkg.T, []pkg.T, map[string]*pkg.T or pkg.List[int].
+//     Qualified identifiers resolve to the packages of TypeImport, or to the packages imported by the current pkg.
+//     Type expressions containing commas must be quoted, e.g.: type="map[string]pkg.Pair[int, string]".
 //
 //   - TypeImport (optional string) defines package import for the specific type.
+//     Unqualified identifiers of Type resolve to the predeclared types, then to the types of this package, then to
+//     the types of the current pkg.
+//     Several import paths can be separated by semicolons and quoted, e.g.: typeImport="go/ast;go/token".
 //
 //   - Exported indicates if the ValueFunc should be exported or not.
 //     The ValueFunc is exported by default.
+//
+//   - Setters (optional bool) indicates if the producer-side funcs SetName(v T) error, MustSetName(v T) and
+//     InitName() di.Value[T] should be generated.
+//     Setters are not generated by default.
+//
+//   - Fake (optional bool) indicates if the FakeGenerator should generate a fake implementation of the interface
+//     type T. Fakes are not generated by default.
 type ValueFuncGenerator struct {
 	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	// The header is a text/template, see HeaderData. Without a header file, the header names the generated package.
 	HeaderFile string `marker:",optional"`
 
-	// Year specifies the year to substitute for " YEAR" in the header file.
+	// Year specifies the year to substitute for " YEAR" and {{ .Year }} in the header file.
 	Year string `marker:",optional"`
+
+	// Filename specifies the name of the generated file. The file is named zz_generated.di.valuefunc.go by default.
+	Filename string `marker:",optional"`
+
+	// BuildTag specifies a build constraint expression, e.g. "!wasm", that must be satisfied to build the generated
+	// file, in addition to !ignore_autogenerated.
+	BuildTag string `marker:",optional"`
+
+	// SplitByContainer generates one file per di.Container, suffixed with the name of the di.Container, e.g.
+	// zz_generated.di.valuefunc.App.go. The ValueFuncs are generated in a single file by default.
+	SplitByContainer bool `marker:",optional"`
+
+	// Tests generates a zz_generated.di.valuefunc_test.go file testing, for each ValueFunc, that the Value it
+	// initializes is the Value it gets from a new empty di.Container, and that the keys of the ValueFuncs of each
+	// di.Container are unique. Tests are not generated by default.
+	Tests bool `marker:",optional"`
 }
 
 func (ValueFuncGenerator) RegisterMarkers(into *markers.Registry) error {
-	if err := markers.RegisterAll(into, ValueFuncMarkerDefinition); err != nil {
+	// ContainerMarkerDefinition is registered to validate the containers of the ValueFuncs.
+	if err := markers.RegisterAll(into, ValueFuncMarkerDefinition, ContainerMarkerDefinition); err != nil {
 		return err //nolint:wrapcheck
 	}
 
@@ -123,71 +255,168 @@ func (g ValueFuncGenerator) Generate(ctx *genall.GenerationContext) error {
 			root.AddError(err)
 		}
 
+		if errs := validateMarkers(ctx.Collector.Registry, root); len(errs) > 0 {
+			addErrors(root, errs...)
+
+			continue
+		}
+
 		markerValues := markerSet[ValueFuncMarkerDefinition.Name]
 		if len(markerValues) == 0 {
 			continue
 		}
 
-		// We create one zz_generated.di.container.go per package
-		// Thus we also instantiate one jen.File per package.
-		f := jen.NewFilePath(root.PkgPath) //nolint:varnamelen
+		sortMarkerValues(markerValues, func(value interface{}) string {
+			valueFunc := value.(ValueFunc) //nolint:forcetypeassert
 
-		for _, markerValue := range markerValues {
-			valueFunc := markerValue.(ValueFunc) //nolint:forcetypeassert
+			return valueFunc.funcName()
+		})
 
-			var typeStt *jen.Statement
-			if valueFunc.TypeImport != nil {
-				typeStt = jen.Qual(*valueFunc.TypeImport, title(valueFunc.Type))
-			} else {
-				typeStt = jen.Id(valueFunc.Type)
-			}
+		filename := outputFilename(g.Filename, ValueFuncMarkerName)
+		files := make(map[string][]interface{})
 
-			var containerStt *jen.Statement
-			if valueFunc.Container != nil {
-				// We use a jen.Id instead of a jen.Qual because Container should always resolve to a container defined
-				// in the current package
-				containerStt = jen.Id(*valueFunc.Container)
-			} else {
-				containerStt = jen.Qual(diutil.PkgPath, "DefaultContainer")
+		for _, markerValue := range markerValues {
+			name := filename
+			if g.SplitByContainer {
+				valueFunc := markerValue.(ValueFunc) //nolint:forcetypeassert
+				name = splitFilename(filename, valueFunc.containerName(root.PkgPath))
 			}
 
-			// func Name(options ...di.Option) di.Value[typeimport.Type] {
-			//  	return di.MustWithOptions[typeimport.Type](ContainerName, "Name", options...)
-			// }
-			f.Func().Id(title(valueFunc.nameWithExportedCasing())).
-				Params(jen.Id("options").Op(" ...").Qual(diutil.PkgPath, "Option")).
-				Qual(diutil.PkgPath, "Value").Types(typeStt).
-				Block(
-					jen.Return().Qual(diutil.PkgPath, "MustWithOptions").
-						Types(typeStt).
-						Call(
-							containerStt,
-							jen.Lit(valueFunc.nameWithExportedCasing()),
-							jen.Id("options").Op("..."),
-						),
-				)
+			files[name] = append(files[name], markerValue)
 		}
 
-		buffer := &bytes.Buffer{}
-		if err = f.Render(buffer); err != nil {
+		for _, name := range sortedKeys(files) {
+			if err := g.generateFile(ctx, root, name, files[name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// generateFile generates the ValueFuncs of the markerValues of root in the file filename.
+func (g ValueFuncGenerator) generateFile(ctx *genall.GenerationContext, root *loader.Package, filename string,
+	markerValues []interface{},
+) error {
+	// We create one jen.File per generated file.
+	f := jen.NewFilePath(root.PkgPath) //nolint:varnamelen
+	resolver := newTypeResolver(root)
+	hasErrors := false
+
+	for _, markerValue := range markerValues {
+		valueFunc := markerValue.(ValueFunc) //nolint:forcetypeassert
+
+		typeStt, err := valueFunc.typeCode(resolver)
+		if err != nil {
 			root.AddError(err)
 
-			return err //nolint:wrapcheck
+			hasErrors = true
+
+			continue
 		}
 
-		if err = generateFile(generateFileOptions{
-			buffer:     buffer,
-			ctx:        ctx,
-			filename:   generatedFilename(DIMarkerName, ValueFuncMarkerName),
-			headerFile: g.HeaderFile,
-			headerYear: g.Year,
-			root:       root,
-		}); err != nil {
-			root.AddError(err)
+		f.Line()
+
+		// func Name(options ...di.Option) di.Value[typeimport.Type] {
+		//  	return di.MustWithOptions[typeimport.Type](ContainerName, "Name", options...)
+		// }
+		f.Func().Id(valueFunc.funcName()).
+			Params(jen.Id("options").Op(" ...").Qual(diutil.PkgPath, "Option")).
+			Qual(diutil.PkgPath, "Value").Types(typeStt).
+			Block(
+				jen.Return().Qual(diutil.PkgPath, "MustWithOptions").
+					Types(typeStt).
+					Call(
+						valueFunc.containerCode(),
+						jen.Lit(valueFunc.key()),
+						jen.Id("options").Op("..."),
+					),
+			)
 
-			return err
+		if valueFunc.hasSetters() {
+			setterFuncsCode(f, valueFunc, typeStt)
 		}
 	}
 
+	if hasErrors {
+		return nil
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := f.Render(buffer); err != nil {
+		root.AddError(err)
+
+		return err //nolint:wrapcheck
+	}
+
+	if err := generateFile(generateFileOptions{
+		buffer:     buffer,
+		generator:  ValueFuncMarkerName,
+		ctx:        ctx,
+		filename:   filename,
+		buildTag:   g.BuildTag,
+		headerFile: g.HeaderFile,
+		year:       g.Year,
+		root:       root,
+	}); err != nil {
+		root.AddError(err)
+
+		return err
+	}
+
+	if g.Tests {
+		return g.generateTestFile(ctx, root, testFilename(filename), markerValues)
+	}
+
 	return nil
 }
+
+// setterFuncsCode generates the producer-side funcs of valueFunc:
+//
+//	func SetName(v typeimport.Type) error {
+//		return di.Set[typeimport.Type](ContainerName, di.NewValue[typeimport.Type]("Name", &v))
+//	}
+//
+//	func MustSetName(v typeimport.Type) {
+//		if err := SetName(v); err != nil {
+//			panic(err)
+//		}
+//	}
+//
+//	func InitName() di.Value[typeimport.Type] {
+//		return di.MustWithOptions[typeimport.Type](ContainerName, "Name", di.InitializeOption)
+//	}
+func setterFuncsCode(f *jen.File, valueFunc ValueFunc, typeStt *jen.Statement) {
+	setName := "Set" + valueFunc.funcName()
+
+	f.Line()
+	f.Commentf("%s sets v as the Value %q.", setName, valueFunc.key())
+	f.Func().Id(setName).Params(jen.Id("v").Add(typeStt)).Error().Block(
+		jen.Return().Qual(diutil.PkgPath, "Set").Types(typeStt).Call(
+			valueFunc.containerCode(),
+			jen.Qual(diutil.PkgPath, "NewValue").Types(typeStt).
+				Call(jen.Lit(valueFunc.key()), jen.Op("&").Id("v")),
+		),
+	)
+
+	f.Line()
+	f.Commentf("Must%s sets v as the Value %q, and panics if an error occurs.", setName, valueFunc.key())
+	f.Func().Id("Must" + setName).Params(jen.Id("v").Add(typeStt)).Block(
+		jen.If(jen.Err().Op(":=").Id(setName).Call(jen.Id("v")), jen.Err().Op("!=").Nil()).Block(
+			jen.Panic(jen.Err()),
+		),
+	)
+
+	initName := "Init" + valueFunc.funcName()
+
+	f.Line()
+	f.Commentf("%s initializes the Value %q, so it can be set through the returned di.Value.", initName, valueFunc.key())
+	f.Func().Id(initName).Params().Qual(diutil.PkgPath, "Value").Types(typeStt).Block(
+		jen.Return().Qual(diutil.PkgPath, "MustWithOptions").Types(typeStt).Call(
+			valueFunc.containerCode(),
+			jen.Lit(valueFunc.key()),
+			jen.Qual(diutil.PkgPath, "InitializeOption"),
+		),
+	)
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gen
+
+//nolint:depguard
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandremahdhaoui/di/pkg/diutil"
+	"github.com/dave/jennifer/jen"
+	"go/types"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"strings"
+)
+
+// testFilename returns the name of the test file of the generated file filename, e.g.:
+// zz_generated.di.valuefunc_test.go.
+func testFilename(filename string) string {
+	return strings.TrimSuffix(filename, ".go") + "_test.go"
+}
+
+// generateTestFile generates the tests of the ValueFuncs of the markerValues of root in the file filename:
+//   - a TestNameValueFunc test per ValueFunc, checking that the Value initialized by the ValueFunc is the Value it
+//     gets, and its setters if any.
+//   - a TestContainerValueFuncKeys test per di.Container, checking that the ValueFuncs of the di.Container have
+//     unique keys.
+//
+// The tests replace the di.Containers with new empty ones until the end of the test, thus they must not run in
+// parallel.
+func (g ValueFuncGenerator) generateTestFile(ctx *genall.GenerationContext, root *loader.Package, filename string,
+	markerValues []interface{},
+) error {
+	f := jen.NewFilePath(root.PkgPath) //nolint:varnamelen
+	resolver := newTypeResolver(root)
+	containers := make(map[string][]ValueFunc)
+
+	for _, markerValue := range markerValues {
+		valueFunc := markerValue.(ValueFunc) //nolint:forcetypeassert
+
+		// the type was resolved when generating the ValueFuncs.
+		typ, err := resolver.resolve(valueFunc.Type, valueFunc.TypeImport)
+		if err != nil {
+			return fmt.Errorf("valuefunc %s: %w", valueFunc.Name, err)
+		}
+
+		valueStt, err := testValueCode(valueFunc, typ)
+		if err != nil {
+			return fmt.Errorf("valuefunc %s: %w", valueFunc.Name, err)
+		}
+
+		name := valueFunc.containerName(root.PkgPath)
+		containers[name] = append(containers[name], valueFunc)
+
+		f.Line()
+		valueFuncTestCode(f, valueFunc, valueStt, types.Comparable(typ))
+	}
+
+	for _, name := range sortedKeys(containers) {
+		f.Line()
+		keysTestCode(f, name, containers[name])
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := f.Render(buffer); err != nil {
+		root.AddError(err)
+
+		return err //nolint:wrapcheck
+	}
+
+	if err := generateFile(generateFileOptions{
+		buffer:     buffer,
+		generator:  ValueFuncMarkerName,
+		ctx:        ctx,
+		filename:   filename,
+		buildTag:   g.BuildTag,
+		headerFile: g.HeaderFile,
+		year:       g.Year,
+		root:       root,
+	}); err != nil {
+		root.AddError(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// freshContainerCode replaces the di.Container of valueFunc with a new empty one until the end of the test:
+//
+//	previous := ContainerName
+//	ContainerName = di.New(previous.Name())
+//	t.Cleanup(func() { ContainerName = previous })
+func freshContainerCode(valueFunc ValueFunc) []jen.Code {
+	return []jen.Code{
+		jen.Id("previous").Op(":=").Add(valueFunc.containerCode()),
+		valueFunc.containerCode().Op("=").Qual(diutil.PkgPath, "New").Call(jen.Id("previous").Dot("Name").Call()),
+		jen.Id("t").Dot("Cleanup").Call(jen.Func().Params().Block(
+			valueFunc.containerCode().Op("=").Id("previous"),
+		)),
+	}
+}
+
+// testValueCode declares the Value v set by the test of valueFunc of type typ: a non-zero Value for the basic and
+// pointer types, so that the test tells it apart from the zero Value of a new di.Container, or the zero Value
+// otherwise:
+//
+//	var v typeimport.Type = 1
+func testValueCode(valueFunc ValueFunc, typ types.Type) (*jen.Statement, error) {
+	typeStt, err := typeCode(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	stt := jen.Var().Id("v").Add(typeStt)
+
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return stt.Op("=").True(), nil
+		case t.Info()&types.IsString != 0:
+			return stt.Op("=").Lit(valueFunc.key()), nil
+		case t.Info()&types.IsNumeric != 0:
+			return stt.Op("=").Lit(1), nil
+		}
+	case *types.Pointer:
+		elemStt, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return stt.Op("=").New(elemStt), nil
+	}
+
+	return stt, nil
+}
+
+// valueFuncTestCode generates the test of valueFunc, declaring the Value v with valueStt. The Values of the types that
+// are not comparable are compared with reflect.DeepEqual:
+//
+//	func TestNameValueFunc(t *testing.T) {
+//		previous := ContainerName
+//		ContainerName = di.New(previous.Name())
+//		t.Cleanup(func() { ContainerName = previous })
+//
+//		initialized := Name(di.InitializeOption)
+//		if key := initialized.Key(); key != "Name" {
+//			t.Fatalf("expected the key %q, got %q", "Name", key)
+//		}
+//
+//		var v typeimport.Type = 1
+//		if err := initialized.Set(v); err != nil {
+//			t.Fatalf("cannot set the Value %q: %v", "Name", err)
+//		}
+//
+//		if got := *Name().MustPtr(); got != v {
+//			t.Fatalf("expected Name() to get the Value %q set by Name(di.InitializeOption), got %v", "Name", got)
+//		}
+//	}
+func valueFuncTestCode(f *jen.File, valueFunc ValueFunc, valueStt *jen.Statement, isComparable bool) {
+	name, key := valueFunc.funcName(), valueFunc.key()
+
+	equal := jen.Id("got").Op("!=").Id("v")
+	if !isComparable {
+		equal = jen.Op("!").Qual("reflect", "DeepEqual").Call(jen.Id("got"), jen.Id("v"))
+	}
+
+	body := freshContainerCode(valueFunc)
+	body = append(body,
+		jen.Line(),
+		jen.Id("initialized").Op(":=").Id(name).Call(jen.Qual(diutil.PkgPath, "InitializeOption")),
+		jen.If(
+			jen.Id("key").Op(":=").Id("initialized").Dot("Key").Call(),
+			jen.Id("key").Op("!=").Lit(key),
+		).Block(
+			jen.Id("t").Dot("Fatalf").Call(jen.Lit("expected the key %q, got %q"), jen.Lit(key), jen.Id("key")),
+		),
+		jen.Line(),
+		valueStt,
+		jen.If(
+			jen.Err().Op(":=").Id("initialized").Dot("Set").Call(jen.Id("v")),
+			jen.Err().Op("!=").Nil(),
+		).Block(
+			jen.Id("t").Dot("Fatalf").Call(jen.Lit("cannot set the Value %q: %v"), jen.Lit(key), jen.Err()),
+		),
+		jen.Line(),
+		jen.If(
+			jen.Id("got").Op(":=").Op("*").Id(name).Call().Dot("MustPtr").Call(),
+			equal,
+		).Block(
+			jen.Id("t").Dot("Fatalf").Call(
+				jen.Lit("expected "+name+"() to get the Value %q set by "+name+"(di.InitializeOption), got %v"),
+				jen.Lit(key),
+				jen.Id("got"),
+			),
+		),
+	)
+
+	if valueFunc.hasSetters() {
+		body = append(body, settersTestCode(valueFunc)...)
+	}
+
+	f.Func().Id("Test" + name + "ValueFunc").Params(jen.Id("t").Op("*").Qual("testing", "T")).Block(body...)
+}
+
+// settersTestCode generates the test of the setters of valueFunc:
+//
+//	if err := SetName(v); err != nil {
+//		t.Fatalf("cannot set the Value %q: %v", "Name", err)
+//	}
+//
+//	if _, err := Name().Value(); err != nil {
+//		t.Fatalf("cannot get the Value %q set by SetName: %v", "Name", err)
+//	}
+//
+//	if key := InitName().Key(); key != "Name" {
+//		t.Fatalf("expected the key %q, got %q", "Name", key)
+//	}
+func settersTestCode(valueFunc ValueFunc) []jen.Code {
+	name, key := valueFunc.funcName(), valueFunc.key()
+
+	return []jen.Code{
+		jen.Line(),
+		jen.If(
+			jen.Err().Op(":=").Id("Set"+name).Call(jen.Id("v")),
+			jen.Err().Op("!=").Nil(),
+		).Block(
+			jen.Id("t").Dot("Fatalf").Call(jen.Lit("cannot set the Value %q: %v"), jen.Lit(key), jen.Err()),
+		),
+		jen.Line(),
+		jen.If(
+			jen.List(jen.Id("_"), jen.Err()).Op(":=").Id(name).Call().Dot("Value").Call(),
+			jen.Err().Op("!=").Nil(),
+		).Block(
+			jen.Id("t").Dot("Fatalf").Call(
+				jen.Lit("cannot get the Value %q set by Set"+name+": %v"), jen.Lit(key), jen.Err(),
+			),
+		),
+		jen.Line(),
+		jen.If(
+			jen.Id("key").Op(":=").Id("Init"+name).Call().Dot("Key").Call(),
+			jen.Id("key").Op("!=").Lit(key),
+		).Block(
+			jen.Id("t").Dot("Fatalf").Call(jen.Lit("expected the key %q, got %q"), jen.Lit(key), jen.Id("key")),
+		),
+	}
+}
+
+// keysTestCode generates the test of the keys of the valueFuncs of the di.Container container:
+//
+//	func TestContainerNameValueFuncKeys(t *testing.T) {
+//		previous := ContainerName
+//		ContainerName = di.New(previous.Name())
+//		t.Cleanup(func() { ContainerName = previous })
+//
+//		ContainerName.AddObserver(di.ObserverFunc(func(e di.Event) {
+//			if e.Kind == di.ValueOverriddenEvent {
+//				t.Errorf("the key %q is used by several ValueFuncs of the container %q", e.Key, e.Container)
+//			}
+//		}))
+//
+//		Name(di.InitializeOption)
+//	}
+func keysTestCode(f *jen.File, container string, valueFuncs []ValueFunc) {
+	body := freshContainerCode(valueFuncs[0])
+	body = append(body,
+		jen.Line(),
+		valueFuncs[0].containerCode().Dot("AddObserver").Call(jen.Qual(diutil.PkgPath, "ObserverFunc").Call(
+			jen.Func().Params(jen.Id("e").Qual(diutil.PkgPath, "Event")).Block(
+				jen.If(jen.Id("e").Dot("Kind").Op("==").Qual(diutil.PkgPath, "ValueOverriddenEvent")).Block(
+					jen.Id("t").Dot("Errorf").Call(
+						jen.Lit("the key %q is used by several ValueFuncs of the container %q"),
+						jen.Id("e").Dot("Key"),
+						jen.Id("e").Dot("Container"),
+					),
+				),
+			),
+		)),
+		jen.Line(),
+	)
+
+	for _, valueFunc := range valueFuncs {
+		body = append(body, jen.Id(valueFunc.funcName()).Call(jen.Qual(diutil.PkgPath, "InitializeOption")))
+	}
+
+	testName := "Test" + title(strings.ReplaceAll(container, ".", "")) + "ValueFuncKeys"
+	f.Func().Id(testName).Params(jen.Id("t").Op("*").Qual("testing", "T")).Block(body...)
+}
@@ -29,15 +29,171 @@ func (ContainerGenerator) Help() *markers.DefinitionHelp {
 		Category: "object",
 		DetailedHelp: markers.DetailedHelp{
 			Summary: "Conveniently generates a new Container ",
-			Details: "Fields: \n - Name (string) identifies the container to be created. \n - Exported (optional bool) indicates if the Container should be exported or not. The Container is not exported by default.",
+			Details: "Fields: \n - Name (string) identifies the container to be created. \n - Exported (optional bool) indicates if the Container should be exported or not. The Container is not exported by default. \n - Typed (optional bool) indicates if a typed container struct should be generated. The struct exposes one accessor per ValueFunc bound to the Container, and the Build and Validate methods. The typed container is not generated by default.",
 		},
 		FieldHelp: map[string]markers.DetailedHelp{
 			"HeaderFile": {
-				Summary: "specifies the header text (e.g. license) to prepend to generated files.",
+				Summary: "specifies the header text (e.g. license) to prepend to generated files. The header is a text/template, see HeaderData. Without a header file, the header names the generated package.",
 				Details: "",
 			},
 			"Year": {
-				Summary: "specifies the year to substitute for \" YEAR\" in the header file.",
+				Summary: "specifies the year to substitute for \" YEAR\" and {{ .Year }} in the header file.",
+				Details: "",
+			},
+			"Filename": {
+				Summary: "specifies the name of the generated file. The file is named zz_generated.di.container.go by default.",
+				Details: "",
+			},
+			"BuildTag": {
+				Summary: "specifies a build constraint expression, e.g. \"!wasm\", that must be satisfied to build the generated file, in addition to !ignore_autogenerated.",
+				Details: "",
+			},
+			"SplitByContainer": {
+				Summary: "generates one file per Container, suffixed with the name of the Container, e.g. zz_generated.di.container.App.go. The Containers are generated in a single file by default.",
+				Details: "",
+			},
+		},
+	}
+}
+
+func (DocsGenerator) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "object",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "writes a human-readable documentation of the wiring of the packages: the Containers, the Values bound to each Container with their types, the packages producing and consuming them, and the doc comments of the markers. ",
+			Details: "Containers are declared by container markers, or by package-level vars initialized with di.New. The producers of a Value are the packages calling its setters or the Set method of its ValueFunc, the consumers are the packages referencing its ValueFunc otherwise. \n The documentation is not associated with a package: it is written to the directory of the output rule, e.g. output:docs:dir=., as DI.md or DI.html. The packages of several modules, e.g. of a go.work, are documented per module: the documentation of each module is written to the directory of the module, relative to the closest directory containing all the modules, e.g. a/DI.md and b/DI.md for the modules of the directories a and b.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"Format": {
+				Summary: "specifies the format of the documentation: markdown or html. The documentation is written in markdown by default.",
+				Details: "",
+			},
+		},
+	}
+}
+
+func (EntrypointGenerator) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "object",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "generates the entrypoint of the containers declared in each package, by the container marker or by a di.New var, including the unexported ones: ",
+			Details: "- The BuildContainers() func, building every container of the package. \n - The ValidateContainers() error func, returning an error if any Value declared by the package is not registered with its type in its container, without calling the providers of the Values. The Values are declared by the valuefunc markers, including the ones bound to the default container or to the container of another package, and by the +di:provide markers. \n Thus, main can build and check the wiring of a package without accessing its unexported containers.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"HeaderFile": {
+				Summary: "specifies the header text (e.g. license) to prepend to generated files. The header is a text/template, see HeaderData. Without a header file, the header names the generated package.",
+				Details: "",
+			},
+			"Year": {
+				Summary: "specifies the year to substitute for \" YEAR\" and {{ .Year }} in the header file.",
+				Details: "",
+			},
+			"Filename": {
+				Summary: "specifies the name of the generated file. The file is named zz_generated.di.entrypoint.go by default.",
+				Details: "",
+			},
+			"BuildTag": {
+				Summary: "specifies a build constraint expression, e.g. \"!wasm\", that must be satisfied to build the generated file, in addition to !ignore_autogenerated.",
+				Details: "",
+			},
+		},
+	}
+}
+
+func (FakeGenerator) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "object",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "generates fake implementations of the interface types of the ValueFuncs marked with fake=true. ",
+			Details: "For a ValueFunc named Name, the FakeGenerator generates: \n - The FakeName struct implementing the interface. For each Method of the interface, FakeName records the arguments of the calls in MethodCalls, and calls the optional MethodStub func. The zero values are returned if MethodStub is nil. \n - The InstallFakeName(fake *FakeName) error func, setting the fake as the Value of the ValueFunc in its container.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"HeaderFile": {
+				Summary: "specifies the header text (e.g. license) to prepend to generated files. The header is a text/template, see HeaderData. Without a header file, the header names the generated package.",
+				Details: "",
+			},
+			"Year": {
+				Summary: "specifies the year to substitute for \" YEAR\" and {{ .Year }} in the header file.",
+				Details: "",
+			},
+			"Filename": {
+				Summary: "specifies the name of the generated file. The file is named zz_generated.di.fake.go by default.",
+				Details: "",
+			},
+			"BuildTag": {
+				Summary: "specifies a build constraint expression, e.g. \"!wasm\", that must be satisfied to build the generated file, in addition to !ignore_autogenerated.",
+				Details: "",
+			},
+		},
+	}
+}
+
+func (InjectorGenerator) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "object",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "generates injector funcs wiring constructors in dependency order with plain Go calls. ",
+			Details: "Markers: \n - +di:injector:provide on a func marks it as the provider of the type of its first result. The func may return an error as its second result. The +di:provide markers of the provide generator are ignored: a func marked with both markers is called by the injector funcs, and provides a di.Value. \n - +di:injector:name=<name> on a func generates an injector func named <name>, which calls the providers of the func's dependencies in dependency order, then the func itself, and returns its result and an error. Each dependency must be provided by exactly one +di:injector:provide func of the package.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"HeaderFile": {
+				Summary: "specifies the header text (e.g. license) to prepend to generated files. The header is a text/template, see HeaderData. Without a header file, the header names the generated package.",
+				Details: "",
+			},
+			"Year": {
+				Summary: "specifies the year to substitute for \" YEAR\" and {{ .Year }} in the header file.",
+				Details: "",
+			},
+			"Filename": {
+				Summary: "specifies the name of the generated file. The file is named zz_generated.di.injector.go by default.",
+				Details: "",
+			},
+			"BuildTag": {
+				Summary: "specifies a build constraint expression, e.g. \"!wasm\", that must be satisfied to build the generated file, in addition to !ignore_autogenerated.",
+				Details: "",
+			},
+		},
+	}
+}
+
+func (ManifestGenerator) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "object",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "writes a manifest describing the Containers and ValueFuncs of all the packages, e.g. for tools consuming the wiring without parsing Go. ",
+			Details: "The manifest is not associated with a package: it is written to the directory of the output rule, e.g. output:manifest:dir=./config, as di-manifest.yaml or di-manifest.json.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"Format": {
+				Summary: "specifies the format of the manifest: yaml or json. The manifest is written in yaml by default.",
+				Details: "",
+			},
+		},
+	}
+}
+
+func (ProvideGenerator) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "object",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "generates ValueFuncs and di.Providers from Go declarations, instead of package markers. ",
+			Details: "Markers: \n - +di:provide on a type declaration generates the ValueFunc of a Value of this type. If the type is a struct with fields marked with +di:inject, a di.Provider constructing it is also generated. \n - +di:provide on a constructor func generates the ValueFunc of a Value of the type of its first result, and a di.Provider calling the constructor with the Values of its parameters. The func may return an error as its second result. \n - +di:inject on a struct field generates the InjectValues(c di.Container) error method of the struct, setting the field with the Value of its type, or the Value identified by name. \n The di.Providers and the InjectValues methods read the Values of their dependencies from c, the di.Container they are installed in, or from the di.Container of the dependency when it is provided in another di.Container. \n Fields of +di:provide: \n - Name (optional string) identifies the func used to access the Value, and the key of the Value. Name defaults to the name of the type followed by \"Value\", e.g.: ConfigValue. \n - Container (optional string) specifies the di.Container that will be used to store the Value. Container resolves to a di.Container defined in the current pkg, or in another pkg when it is qualified with the import path of the pkg, e.g.: container=example.com/app/wiring.App. The default container is used by default. \n Fields of +di:inject: \n - Name (optional string) is the key of the injected Value. By default, the Value is the only Value of the package provided with the type of the field.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"HeaderFile": {
+				Summary: "specifies the header text (e.g. license) to prepend to generated files. The header is a text/template, see HeaderData. Without a header file, the header names the generated package.",
+				Details: "",
+			},
+			"Year": {
+				Summary: "specifies the year to substitute for \" YEAR\" and {{ .Year }} in the header file.",
+				Details: "",
+			},
+			"Filename": {
+				Summary: "specifies the name of the generated file. The file is named zz_generated.di.provide.go by default.",
+				Details: "",
+			},
+			"BuildTag": {
+				Summary: "specifies a build constraint expression, e.g. \"!wasm\", that must be satisfied to build the generated file, in addition to !ignore_autogenerated.",
 				Details: "",
 			},
 		},
@@ -49,15 +205,31 @@ func (ValueFuncGenerator) Help() *markers.DefinitionHelp {
 		Category: "object",
 		DetailedHelp: markers.DetailedHelp{
 			Summary: "Creates a single func to conveniently access a di.Value. This marker is also used by the di-checker to create the dependency graph. ",
-			Details: "Fields: \n - Name (string) identifies the func that will be used to access the defined value. \n - Container (optional string) specifies the di.Container's Name that will be used to store the Value. Container should always resolve to a di.Container defined in the current pkg. In other words, the \"consumer\" of a di.Value, defines both the di.Value and the di.Container in the same package where the di.Value is consumed. It's the job of the \"producer\" of the injectable value to import the ValueFunc from the getter package. In use cases where an interface is necessary to decouple \"consumer\" and the \"producer\", it is a best practice to create an \"interface package\" that defines both di.Value & di.Container, which can be imported by the \"consumers\" and the \"producers\" (!! Concurrent producers should NEVER be allowed: greatly reduce the side effects) \n - Type (string) defines the type T to the Value[T]. \n - TypeImport (optional string) defines package import for the specific type. \n - Exported indicates if the ValueFunc should be exported or not. The ValueFunc is exported by default.",
+			Details: "Fields: \n - Name (string) identifies the func that will be used to access the defined value. \n - Container (optional string) specifies the di.Container's Name that will be used to store the Value. Container resolves to a di.Container defined in the current pkg, or in another pkg when it is qualified with the import path of the pkg, e.g.: container=example.com/app/wiring.App. The di.Container of another pkg must be exported. In other words, the \"consumer\" of a di.Value, defines both the di.Value and the di.Container in the same package where the di.Value is consumed. It's the job of the \"producer\" of the injectable value to import the ValueFunc from the getter package. In use cases where an interface is necessary to decouple \"consumer\" and the \"producer\", it is a best practice to create an \"interface package\" that defines both di.Value & di.Container, which can be imported by the \"consumers\" and the \"producers\" (!! Concurrent producers should NEVER be allowed: greatly reduce the side effects) \n - Type (string) defines the type T to the Value[T]. Type accepts any Go type expression, e.g.: *pkg.T, []pkg.T, map[string]*pkg.T or pkg.List[int]. Qualified identifiers resolve to the packages of TypeImport, or to the packages imported by the current pkg. Type expressions containing commas must be quoted, e.g.: type=\"map[string]pkg.Pair[int, string]\". \n - TypeImport (optional string) defines package import for the specific type. Unqualified identifiers of Type resolve to the predeclared types, then to the types of this package, then to the types of the current pkg. Several import paths can be separated by semicolons and quoted, e.g.: typeImport=\"go/ast;go/token\". \n - Exported indicates if the ValueFunc should be exported or not. The ValueFunc is exported by default. \n - Setters (optional bool) indicates if the producer-side funcs SetName(v T) error, MustSetName(v T) and InitName() di.Value[T] should be generated. Setters are not generated by default. \n - Fake (optional bool) indicates if the FakeGenerator should generate a fake implementation of the interface type T. Fakes are not generated by default.",
 		},
 		FieldHelp: map[string]markers.DetailedHelp{
 			"HeaderFile": {
-				Summary: "specifies the header text (e.g. license) to prepend to generated files.",
+				Summary: "specifies the header text (e.g. license) to prepend to generated files. The header is a text/template, see HeaderData. Without a header file, the header names the generated package.",
 				Details: "",
 			},
 			"Year": {
-				Summary: "specifies the year to substitute for \" YEAR\" in the header file.",
+				Summary: "specifies the year to substitute for \" YEAR\" and {{ .Year }} in the header file.",
+				Details: "",
+			},
+			"Filename": {
+				Summary: "specifies the name of the generated file. The file is named zz_generated.di.valuefunc.go by default.",
+				Details: "",
+			},
+			"BuildTag": {
+				Summary: "specifies a build constraint expression, e.g. \"!wasm\", that must be satisfied to build the generated file, in addition to !ignore_autogenerated.",
+				Details: "",
+			},
+			"SplitByContainer": {
+				Summary: "generates one file per di.Container, suffixed with the name of the di.Container, e.g. zz_generated.di.valuefunc.App.go. The ValueFuncs are generated in a single file by default.",
+				Details: "",
+			},
+			"Tests": {
+				Summary: "generates a zz_generated.di.valuefunc_test.go file testing, for each ValueFunc, that the Value it initializes is the Value it gets from a new empty di.Container, and that the keys of the ValueFuncs of each di.Container are unique. Tests are not generated by default.",
 				Details: "",
 			},
 		},
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di
+
+import (
+	"fmt"
+)
+
+// Provider registers one or more Values into a Container.
+type Provider func(c Container) error
+
+// Provide registers a constructor for the Value identified by key.
+//
+// The constructor is lazily called once: the first time the Value is accessed, or when the Container is built.
+// The constructor may access other Values of the Container; circular dependencies are reported as errors.
+func Provide[T any](c Container, key string, constructor func(c Container) (T, error)) error {
+	v := NewValue[T](key, nil)
+	if err := c.set(key, v.pointer(), typeOf[T]()); err != nil {
+		return formatErr(fmt.Sprintf("cannot provide Value %q to container %q", key, c.Name()), err.Error())
+	}
+
+	c.provide(key, func() error {
+		item, err := constructor(c)
+		if err != nil {
+			return err
+		}
+
+		return v.Set(item)
+	})
+
+	c.notify(ValueProvidedEvent, key, typeOf[T](), nil)
+
+	return nil
+}
+
+// NewProvider returns a Provider registering the constructor of the Value identified by key. See Provide.
+func NewProvider[T any](key string, constructor func(c Container) (T, error)) Provider {
+	return func(c Container) error {
+		return Provide[T](c, key, constructor)
+	}
+}
+
+// NewValueProvider returns a Provider setting item as the Value identified by key.
+func NewValueProvider[T any](key string, item T) Provider {
+	return func(c Container) error {
+		return Set[T](c, NewValue[T](key, &item))
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di_test
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/alexandremahdhaoui/di" //nolint:depguard
+	. "github.com/onsi/ginkgo/v2"      //nolint:depguard
+	. "github.com/onsi/gomega"         //nolint:depguard
+)
+
+var _ = Describe("Provide", func() {
+	var c di.Container
+	var calls int
+
+	BeforeEach(func() {
+		c = di.New("provide")
+		calls = 0
+
+		Expect(di.Provide[int](c, "port", func(c di.Container) (int, error) {
+			calls++
+
+			return 8080, nil
+		})).To(Succeed())
+
+		Expect(di.Provide[string](c, "addr", func(c di.Container) (string, error) {
+			port, err := di.Get[int](c, "port")
+			if err != nil {
+				return "", err
+			}
+
+			return "localhost:" + strconv.Itoa(port.MustValue()), nil
+		})).To(Succeed())
+	})
+
+	It("should lazily construct Values once", func() {
+		Expect(calls).To(Equal(0))
+
+		Expect(di.Must[string](c, "addr").MustValue()).To(Equal("localhost:8080"))
+		Expect(di.Must[int](c, "port").MustValue()).To(Equal(8080))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("should construct Values when the container is built", func() {
+		c.Build()
+
+		Expect(calls).To(Equal(1))
+		Expect(di.Must[int](c, "port").MustValue()).To(Equal(8080))
+	})
+
+	It("should return constructor errors", func() {
+		Expect(di.Provide[int](c, "failing", func(c di.Container) (int, error) {
+			return 0, errors.New("boom")
+		})).To(Succeed())
+
+		_, err := di.Get[int](c, "failing")
+		Expect(err).To(MatchError(ContainSubstring("boom")))
+		Expect(func() { c.Build() }).To(Panic())
+	})
+
+	It("should detect circular dependencies", func() {
+		Expect(di.Provide[int](c, "a", func(c di.Container) (int, error) {
+			v, err := di.Get[int](c, "b")
+			if err != nil {
+				return 0, err
+			}
+
+			return v.Value()
+		})).To(Succeed())
+
+		Expect(di.Provide[int](c, "b", func(c di.Container) (int, error) {
+			v, err := di.Get[int](c, "a")
+			if err != nil {
+				return 0, err
+			}
+
+			return v.Value()
+		})).To(Succeed())
+
+		_, err := di.Get[int](c, "a")
+		Expect(err).To(MatchError(ContainSubstring("circular dependency")))
+	})
+
+	It("should fail providing to a built container", func() {
+		c.Build()
+
+		Expect(di.Provide[int](c, "late", func(c di.Container) (int, error) { return 0, nil })).NotTo(Succeed())
+	})
+
+	It("should be overridden by Set", func() {
+		Expect(di.Set(c, di.NewValue("port", new(int)))).To(Succeed())
+
+		Expect(di.Must[int](c, "port").MustValue()).To(Equal(0))
+		Expect(calls).To(Equal(0))
+	})
+})
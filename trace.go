@@ -0,0 +1,166 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// Tracer is notified when a provider constructs a Value. See Container.SetTracer.
+	Tracer interface {
+		// StartResolution is called before the provider of r.Key is called. The returned Span is ended once the
+		// provider returns.
+		StartResolution(r Resolution) Span
+	}
+
+	// Span is the construction of a single Value.
+	Span interface {
+		// End is called with the error returned by the provider, if any.
+		End(err error)
+	}
+
+	// Resolution describes the construction of a single Value by a provider.
+	Resolution struct {
+		// Container is the name of the Container holding the Value.
+		Container string
+		// Key identifies the constructed Value.
+		Key string
+		// Parent (optional) is the key of the Value whose construction triggered this resolution.
+		Parent string
+		// Goroutine is the id of the goroutine that constructed the Value.
+		Goroutine uint64
+		// Start is the time at which the provider was called.
+		Start time.Time
+
+		// Order is the position of this resolution among all resolutions recorded by a Recorder.
+		Order int
+		// Duration is the time spent in the provider, including the construction of its dependencies.
+		Duration time.Duration
+		// Err is the error returned by the provider.
+		Err error
+	}
+
+	// Recorder is a Tracer keeping track of every Resolution.
+	Recorder struct {
+		mu          sync.Mutex
+		resolutions []Resolution
+	}
+
+	recorderSpan struct {
+		recorder *Recorder
+		index    int
+	}
+
+	slogTracer struct {
+		logger *slog.Logger
+	}
+
+	slogSpan struct {
+		logger     *slog.Logger
+		resolution Resolution
+	}
+
+	noopSpan struct{}
+)
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) StartResolution(resolution Resolution) Span { //nolint:ireturn
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	resolution.Order = len(r.resolutions)
+	r.resolutions = append(r.resolutions, resolution)
+
+	return &recorderSpan{recorder: r, index: resolution.Order}
+}
+
+// Resolutions returns the recorded resolutions, by order of start.
+func (r *Recorder) Resolutions() []Resolution {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Resolution(nil), r.resolutions...)
+}
+
+func (s *recorderSpan) End(err error) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+
+	resolution := &s.recorder.resolutions[s.index]
+	resolution.Duration = time.Since(resolution.Start)
+	resolution.Err = err
+}
+
+// NewSlogTracer returns a Tracer logging each Resolution once the Value is constructed.
+func NewSlogTracer(logger *slog.Logger) Tracer { //nolint:ireturn
+	return &slogTracer{logger: logger}
+}
+
+func (t *slogTracer) StartResolution(r Resolution) Span { //nolint:ireturn
+	return &slogSpan{logger: t.logger, resolution: r}
+}
+
+func (s *slogSpan) End(err error) {
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("container", s.resolution.Container),
+		slog.String("key", s.resolution.Key),
+		slog.Uint64("goroutine", s.resolution.Goroutine),
+		slog.Duration("duration", time.Since(s.resolution.Start)),
+	}
+
+	if s.resolution.Parent != "" {
+		attrs = append(attrs, slog.String("parent", s.resolution.Parent))
+	}
+
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	s.logger.LogAttrs(context.Background(), level, "value resolved", attrs...)
+}
+
+func (noopSpan) End(error) {}
+
+// goroutineID parses the id of the current goroutine from its stack trace, e.g.: "goroutine 42 [running]:".
+func goroutineID() uint64 {
+	buf := make([]byte, 64) //nolint:gomnd
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		buf = buf[:i]
+	}
+
+	id, err := strconv.ParseUint(string(buf), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2023 Alexandre Mahdhaoui.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package di_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/alexandremahdhaoui/di" //nolint:depguard
+	. "github.com/onsi/ginkgo/v2"      //nolint:depguard
+	. "github.com/onsi/gomega"         //nolint:depguard
+)
+
+var _ = Describe("Tracer", func() {
+	var c di.Container
+
+	BeforeEach(func() {
+		c = di.New("trace")
+
+		Expect(di.Provide[int](c, "db", func(c di.Container) (int, error) {
+			time.Sleep(10 * time.Millisecond)
+
+			return 1, nil
+		})).To(Succeed())
+		Expect(di.Provide[bool](c, "app", func(c di.Container) (bool, error) {
+			_ = di.Must[int](c, "db")
+
+			return true, nil
+		})).To(Succeed())
+		Expect(di.Provide[string](c, "failing", func(c di.Container) (string, error) {
+			return "", errors.New("boom")
+		})).To(Succeed())
+	})
+
+	Context("with a Recorder", func() {
+		It("should record every resolution", func() {
+			recorder := di.NewRecorder()
+			c.SetTracer(recorder)
+
+			_, _ = di.Get[bool](c, "app")
+			_, _ = di.Get[string](c, "failing")
+
+			resolutions := recorder.Resolutions()
+			Expect(resolutions).To(HaveLen(3))
+
+			Expect(resolutions[0].Key).To(Equal("app"))
+			Expect(resolutions[0].Order).To(Equal(0))
+			Expect(resolutions[0].Parent).To(BeEmpty())
+
+			Expect(resolutions[1].Key).To(Equal("db"))
+			Expect(resolutions[1].Order).To(Equal(1))
+			Expect(resolutions[1].Parent).To(Equal("app"))
+			Expect(resolutions[1].Container).To(Equal("trace"))
+			Expect(resolutions[1].Goroutine).NotTo(BeZero())
+			Expect(resolutions[1].Duration).To(BeNumerically(">=", 10*time.Millisecond))
+			Expect(resolutions[0].Duration).To(BeNumerically(">=", resolutions[1].Duration))
+
+			Expect(resolutions[2].Key).To(Equal("failing"))
+			Expect(resolutions[2].Err).To(MatchError("boom"))
+		})
+
+		It("should only record constructions", func() {
+			recorder := di.NewRecorder()
+			c.SetTracer(recorder)
+
+			_, _ = di.Get[int](c, "db")
+			_, _ = di.Get[int](c, "db")
+
+			Expect(recorder.Resolutions()).To(HaveLen(1))
+		})
+	})
+
+	Context("with a slog Tracer", func() {
+		It("should log every resolution", func() {
+			buffer := new(bytes.Buffer)
+			c.SetTracer(di.NewSlogTracer(slog.New(slog.NewTextHandler(buffer, nil))))
+
+			_, _ = di.Get[bool](c, "app")
+			_, _ = di.Get[string](c, "failing")
+
+			Expect(buffer.String()).To(ContainSubstring("level=INFO msg=\"value resolved\" container=trace key=db"))
+			Expect(buffer.String()).To(ContainSubstring("parent=app"))
+			Expect(buffer.String()).To(ContainSubstring("level=ERROR msg=\"value resolved\" container=trace key=failing"))
+			Expect(buffer.String()).To(ContainSubstring("error=boom"))
+		})
+	})
+})
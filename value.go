@@ -69,7 +69,12 @@ func (v *value[T]) MustSet(item T) {
 }
 
 func (v *value[T]) Set(item T) error {
-	*v.ptr = any(item)
+	ptr, err := v.Ptr()
+	if err != nil {
+		return err
+	}
+
+	*ptr = item
 
 	return nil
 }
@@ -121,6 +121,14 @@ var _ = Describe("Value", func() {
 				err := value0.Set(newItem)
 				Expect(err).ShouldNot(HaveOccurred())
 			})
+
+			It("should return the item that was set", func() {
+				value1.MustSet("value1")
+				Expect(value1.MustValue()).To(Equal("value1"))
+
+				value2.MustSet(testStruct{a: 3})
+				Expect(value2.MustValue()).To(Equal(testStruct{a: 3}))
+			})
 		})
 
 		Context("Value", func() {